@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"log"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// BatchFileOperations applies an ordered list of file operations as a unit.
+// Every operation is validated against the project tree as it will look at
+// that point of the batch before anything is changed; if applying one fails,
+// all earlier operations are rolled back.
+func BatchFileOperations(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req types.BatchFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	projectPath := project.GetStoragePath(project.User.Username)
+	entries, err := storage.Store.List(projectPath)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+
+	tree := make(batchTree)
+	for _, entry := range entries {
+		tree[entry.Path] = batchNode{isDir: entry.IsDir, size: entry.Size}
+	}
+
+	results := make([]types.BatchFileResult, len(req.Operations))
+	for i, op := range req.Operations {
+		results[i] = types.BatchFileResult{Op: op.Op, Path: op.Path, Target: op.Target, Status: "skipped"}
+	}
+
+	// Validate every operation before touching storage
+	type batchStep struct {
+		op       types.BatchFileOperation
+		src, dst string // project-relative paths
+	}
+	steps := make([]batchStep, len(req.Operations))
+	var addBytes, addFiles int64
+	for i, op := range req.Operations {
+		src, ok := batchRelPath(projectPath, op.Path)
+		dst := ""
+		if ok && (op.Op == "move" || op.Op == "copy") {
+			dst, ok = batchRelPath(projectPath, op.Target)
+		}
+		if !ok {
+			results[i].Status, results[i].Error = "failed", utils.MsgInvalidFilePath
+			utils.ErrorWithData(c, 400, utils.MsgFileBatchInvalid, results)
+			return
+		}
+
+		deltaBytes, deltaFiles, errCode := tree.apply(op, src, dst)
+		if errCode != "" {
+			results[i].Status, results[i].Error = "failed", errCode
+			utils.ErrorWithData(c, 400, utils.MsgFileBatchInvalid, results)
+			return
+		}
+		addBytes += deltaBytes
+		addFiles += deltaFiles
+		steps[i] = batchStep{op: op, src: src, dst: dst}
+
+		// Check written and renamed files against the owner's content policy
+		warnings, err := checkBatchContentPolicy(&project.User, projectPath, tree, op, src, dst)
+		if err != nil {
+			if results[i].Violations = policyViolations(err); results[i].Violations == nil {
+				utils.InternalServerError(c, utils.MsgInternalError)
+				return
+			}
+			results[i].Status, results[i].Error = "failed", utils.MsgContentPolicyViolation
+			utils.ErrorWithData(c, 422, utils.MsgContentPolicyViolation, results)
+			return
+		}
+		results[i].Violations = toPolicyViolations(warnings)
+	}
+
+	// Check the owner's quota against the net effect of the batch
+	if !checkQuota(c, &project.User, addBytes, addFiles) {
+		return
+	}
+
+	tx, err := services.BeginFileTransaction()
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+
+	// History and trash records are only updated once the whole batch has been applied
+	var afterCommit []func() error
+	var changes []services.FileChange
+	for i, step := range steps {
+		srcFull := path.Join(projectPath, step.src)
+		dstFull := path.Join(projectPath, step.dst)
+
+		var err error
+		switch step.op.Op {
+		case "create", "write":
+			content := []byte(step.op.Content)
+			if step.op.Op == "write" && !services.HasRevisions(project.ID, step.src) {
+				if previous, err := storage.Store.Read(srcFull); err == nil {
+					services.RecordRevision(project.ID, step.src, previous, project.UserID)
+				}
+			}
+			err = tx.Write(srcFull, content)
+			changes = append(changes, services.FileChange{Type: services.FileChangeWrite, Path: step.src})
+			afterCommit = append(afterCommit, func() error {
+				_, err := services.RecordRevision(project.ID, step.src, content, userID.(uint))
+				return err
+			})
+		case "mkdir":
+			err = tx.Mkdir(srcFull)
+		case "delete":
+			// Keep the last content in the file history and move the file to
+			// the trash; the trash record is only saved once the batch succeeds
+			if err = services.RecordDeletedRevisions(project.ID, projectPath, step.src, userID.(uint)); err != nil {
+				break
+			}
+			var item *models.TrashItem
+			if item, err = services.NewTrashItem(project.ID, projectPath, step.src, userID.(uint)); err == nil {
+				err = tx.Move(srcFull, item.GetStoragePath())
+				afterCommit = append(afterCommit, func() error {
+					return services.SaveTrashItem(item)
+				})
+			}
+			changes = append(changes, services.FileChange{Type: services.FileChangeDelete, Path: step.src})
+		case "move":
+			err = tx.Move(srcFull, dstFull)
+			changes = append(changes, services.FileChange{Type: services.FileChangeMove, Path: step.dst, OldPath: step.src})
+			afterCommit = append(afterCommit, func() error {
+				return services.MoveRevisions(project.ID, step.src, step.dst)
+			})
+		case "copy":
+			err = tx.Copy(srcFull, dstFull)
+			changes = append(changes, services.FileChange{Type: services.FileChangeWrite, Path: step.dst})
+		}
+
+		if err != nil {
+			log.Printf("Batch operation %d (%s %s) failed: %v", i, step.op.Op, step.op.Path, err)
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("Failed to roll back batch: %v", rbErr)
+			}
+			for j := 0; j < i; j++ {
+				results[j].Status = "rolled_back"
+			}
+			results[i].Status, results[i].Error = "failed", utils.MsgFileWriteFailed
+			utils.ErrorWithData(c, 500, utils.MsgFileBatchFailed, results)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to clean up batch backups: %v", err)
+	}
+	for _, fn := range afterCommit {
+		if err := fn(); err != nil {
+			log.Printf("Failed to update file records after batch: %v", err)
+		}
+	}
+	for _, change := range changes {
+		services.PublishFileChange(project.ID, change)
+	}
+
+	for i := range results {
+		results[i].Status = "ok"
+	}
+	utils.SuccessWithCode(c, utils.MsgFileBatchApplied, results)
+}
+
+// checkBatchContentPolicy checks an operation that has been applied to tree
+// against the content policy of owner. Written files are checked with their
+// content; moved and copied files only when their extension changes, using
+// the stored content if there is any yet.
+func checkBatchContentPolicy(owner *models.User, projectPath string, tree batchTree, op types.BatchFileOperation, src, dst string) ([]services.PolicyViolation, error) {
+	switch op.Op {
+	case "create", "write":
+		return services.CheckContentPolicy(owner, src, int64(len(op.Content)), strings.NewReader(op.Content))
+	case "move", "copy":
+		if strings.EqualFold(path.Ext(src), path.Ext(dst)) {
+			return nil, nil
+		}
+		srcFull := path.Join(projectPath, src)
+		if storage.Exists(storage.Store, srcFull) {
+			return services.CheckStoredContentPolicy(owner, srcFull, dst)
+		}
+		if node := tree[dst]; !node.isDir {
+			return services.CheckContentPolicy(owner, dst, node.size, nil)
+		}
+	}
+	return nil, nil
+}
+
+// batchRelPath resolves a client supplied path to a project-relative path,
+// rejecting paths outside the project and the project root itself
+func batchRelPath(projectPath, p string) (string, bool) {
+	if p == "" {
+		return "", false
+	}
+	fullPath := path.Join(projectPath, p)
+	if !isPathSafe(fullPath, projectPath) || fullPath == path.Clean(projectPath) {
+		return "", false
+	}
+	return strings.TrimPrefix(fullPath, projectPath+"/"), true
+}
+
+// batchNode is a file or folder of a batchTree
+type batchNode struct {
+	isDir bool
+	size  int64
+}
+
+// batchTree is an in-memory view of a project's files, keyed by
+// project-relative path, used to validate a batch before applying it
+type batchTree map[string]batchNode
+
+// exists reports whether p is a file or folder; the root always exists
+func (t batchTree) exists(p string) bool {
+	_, ok := t[p]
+	return ok || p == ""
+}
+
+// hasFileParent reports whether any parent of p is a file
+func (t batchTree) hasFileParent(p string) bool {
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if node, ok := t[dir]; ok && !node.isDir {
+			return true
+		}
+	}
+	return false
+}
+
+// subtree returns p and every path below it
+func (t batchTree) subtree(p string) []string {
+	paths := []string{p}
+	for name := range t {
+		if strings.HasPrefix(name, p+"/") {
+			paths = append(paths, name)
+		}
+	}
+	return paths
+}
+
+// remove deletes p and everything below it and returns the removed usage
+func (t batchTree) remove(p string) (bytes, files int64) {
+	for _, name := range t.subtree(p) {
+		if node, ok := t[name]; ok && !node.isDir {
+			bytes += node.size
+			files++
+		}
+		delete(t, name)
+	}
+	return bytes, files
+}
+
+// add inserts a node and any missing parent folders
+func (t batchTree) add(p string, node batchNode) {
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if _, ok := t[dir]; !ok {
+			t[dir] = batchNode{isDir: true}
+		}
+	}
+	t[p] = node
+}
+
+// apply validates an operation against the tree and applies it. It returns
+// the change in usage, or the message code explaining why the operation is
+// invalid.
+func (t batchTree) apply(op types.BatchFileOperation, src, dst string) (addBytes, addFiles int64, errCode string) {
+	switch op.Op {
+	case "create":
+		if t.exists(src) {
+			return 0, 0, utils.MsgFileExists
+		}
+		if t.hasFileParent(src) {
+			return 0, 0, utils.MsgInvalidFilePath
+		}
+		t.add(src, batchNode{size: int64(len(op.Content))})
+		return int64(len(op.Content)), 1, ""
+
+	case "write":
+		node, ok := t[src]
+		if !ok {
+			return 0, 0, utils.MsgFileNotFound
+		}
+		if node.isDir {
+			return 0, 0, utils.MsgInvalidRequest
+		}
+		t[src] = batchNode{size: int64(len(op.Content))}
+		return int64(len(op.Content)) - node.size, 0, ""
+
+	case "mkdir":
+		if t.exists(src) {
+			return 0, 0, utils.MsgFileExists
+		}
+		if t.hasFileParent(src) {
+			return 0, 0, utils.MsgInvalidFilePath
+		}
+		t.add(src, batchNode{isDir: true})
+		return 0, 0, ""
+
+	case "delete":
+		// Prevent deleting index.html
+		if src == "index.html" {
+			return 0, 0, utils.MsgInvalidRequest
+		}
+		if !t.exists(src) {
+			return 0, 0, utils.MsgFileNotFound
+		}
+		bytes, files := t.remove(src)
+		return -bytes, -files, ""
+
+	case "move", "copy":
+		// Prevent moving index.html
+		if op.Op == "move" && src == "index.html" {
+			return 0, 0, utils.MsgInvalidRequest
+		}
+		node, ok := t[src]
+		if !ok {
+			return 0, 0, utils.MsgFileNotFound
+		}
+		if dst == src || strings.HasPrefix(dst, src+"/") || t.hasFileParent(dst) {
+			return 0, 0, utils.MsgInvalidFilePath
+		}
+		if dst == "index.html" && node.isDir {
+			return 0, 0, utils.MsgInvalidRequest
+		}
+		if t.exists(dst) && !op.Overwrite {
+			return 0, 0, utils.MsgFileExists
+		}
+
+		removedBytes, removedFiles := t.remove(dst)
+		addBytes, addFiles = -removedBytes, -removedFiles
+
+		for _, name := range t.subtree(src) {
+			moved := dst + strings.TrimPrefix(name, src)
+			n := t[name]
+			if op.Op == "move" {
+				delete(t, name)
+			} else if !n.isDir {
+				addBytes += n.size
+				addFiles++
+			}
+			t.add(moved, n)
+		}
+		return addBytes, addFiles, ""
+	}
+
+	return 0, 0, utils.MsgInvalidRequest
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// authorizeClientRequest authenticates a request from a git or WebDAV client
+// and loads the project it accesses by name. On failure the response is
+// written and false is returned.
+//
+// Clients use HTTP basic auth with a deploy token of the project or a
+// session token as the password, in which case the username is ignored, or
+// the same Bearer header as AuthMiddleware. With allowPassword set, the
+// username and account password are accepted too.
+// The returned user ID is the one changes are attributed to; deploy tokens
+// act as the user who created them.
+func authorizeClientRequest(c *gin.Context, projectName string, allowPassword bool) (*models.Project, uint, bool) {
+	username, credential, isBasic := c.Request.BasicAuth()
+	if !isBasic {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) == 2 && parts[0] == "Bearer" {
+				credential = parts[1]
+			}
+		}
+	}
+	if credential == "" {
+		clientUnauthorized(c)
+		return nil, 0, false
+	}
+
+	// Resolve the credential before looking at the project, so unknown
+	// credentials get a new prompt rather than a hint that the project exists
+	var token *models.DeployToken
+	var user models.User
+	if strings.HasPrefix(credential, services.DeployTokenPrefix) {
+		var err error
+		if token, err = services.FindDeployToken(credential); err != nil {
+			clientUnauthorized(c)
+			return nil, 0, false
+		}
+	} else if claims, err := utils.ParseToken(credential); err == nil {
+		if err := database.DB.First(&user, claims.UserID).Error; err != nil {
+			clientUnauthorized(c)
+			return nil, 0, false
+		}
+	} else {
+		if !allowPassword || !isBasic {
+			clientUnauthorized(c)
+			return nil, 0, false
+		}
+		if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil || !utils.CheckPassword(credential, user.Password) {
+			clientUnauthorized(c)
+			return nil, 0, false
+		}
+	}
+
+	var project models.Project
+	if err := database.DB.Preload("User").Where("name = ?", projectName).First(&project).Error; err != nil {
+		c.String(http.StatusNotFound, "project not found")
+		return nil, 0, false
+	}
+
+	if token != nil {
+		if token.ProjectID != project.ID {
+			c.String(http.StatusNotFound, "project not found")
+			return nil, 0, false
+		}
+		if !project.User.IsActive {
+			c.String(http.StatusForbidden, "account disabled")
+			return nil, 0, false
+		}
+		return &project, token.CreatedBy, true
+	}
+
+	if !user.IsActive {
+		c.String(http.StatusForbidden, "account disabled")
+		return nil, 0, false
+	}
+	if project.UserID != user.ID && !user.IsAdmin() {
+		c.String(http.StatusNotFound, "project not found")
+		return nil, 0, false
+	}
+
+	return &project, user.ID, true
+}
+
+// clientUnauthorized asks a git or WebDAV client for credentials
+func clientUnauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="StaticForge"`)
+	c.String(http.StatusUnauthorized, "authentication required")
+}
@@ -0,0 +1,653 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// Text files are edited together over a WebSocket at
+// /api/projects/{id}/collab?path={path}. The server holds the document
+// while anyone has it open and orders all edits:
+//
+//   - On connect the client receives "init" with the document, its version
+//     and the other editors.
+//   - The client sends "op" with an ot.js text operation and the version it
+//     was made on. The server transforms it against the edits made since,
+//     applies it, answers "ack" with the new version and relays the
+//     transformed operation as "op" to the other editors.
+//   - "cursor" messages carry a selection and are relayed as is; "join" and
+//     "leave" announce editors.
+//
+// Edits are saved every few seconds and when the last editor leaves, the
+// same way as saves from the editor, and announced with "saved". If the
+// file was saved some other way since the document loaded or last saved it,
+// nothing is written: the edits since the last save are dropped and every
+// editor gets a new "init" with the stored file and the message code
+// error_collab_file_changed.
+
+const (
+	// collabMaxFileSize is the largest document, in UTF-16 code units, that
+	// can be edited together
+	collabMaxFileSize = 2 << 20
+	// collabSaveInterval is how often changed documents are saved
+	collabSaveInterval = 5 * time.Second
+	// collabHistorySize is how many past operations are kept to transform
+	// late operations against
+	collabHistorySize = 1000
+
+	collabWriteWait  = 10 * time.Second
+	collabPongWait   = 60 * time.Second
+	collabPingPeriod = collabPongWait * 9 / 10
+)
+
+// errCollabUnsupportedFile is returned for files that are not text or too large
+var errCollabUnsupportedFile = errors.New("file cannot be edited together")
+
+var collabUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// collabDocs holds the open documents by project ID and path. collabMu also
+// guards editors joining and leaving, so a document is never dropped while
+// someone joins it. collabClosing holds the documents still being saved
+// after their last editor left, so reopening them waits for the save.
+var (
+	collabMu      sync.Mutex
+	collabDocs    = map[string]*collabDoc{}
+	collabClosing = map[string]chan struct{}{}
+)
+
+// collabDoc is a file open for collaborative editing
+type collabDoc struct {
+	key         string
+	project     models.Project
+	projectPath string
+	relPath     string
+
+	mu      sync.Mutex
+	content []uint16
+	version int
+	history []utils.TextOperation // the last operations, ending at version
+	clients map[string]*collabClient
+	dirty   bool
+	editor  uint   // user the next save is attributed to
+	hash    string // hash of the content last loaded or saved
+
+	saveMu sync.Mutex
+	done   chan struct{}
+}
+
+// collabClient is an editor connected to a document
+type collabClient struct {
+	id          string
+	userID      uint
+	username    string
+	displayName string
+	anchor      int
+	head        int
+
+	conn   *websocket.Conn
+	send   chan *types.CollabMessage
+	kick   chan struct{} // closed to disconnect an editor that fell behind
+	kicked bool
+}
+
+// CollabEditFile connects an editor to the collaborative editing session of
+// a project file
+func CollabEditFile(c *gin.Context) {
+	projectID := c.Param("id")
+	filePath := c.Query("path")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	if filePath == "" {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	// Get project path
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, filePath)
+
+	// Security check
+	if !isPathSafe(fullPath, projectPath) {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
+		return
+	}
+
+	info, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		utils.NotFound(c, utils.MsgFileNotFound)
+		return
+	}
+	if info.IsDir {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.NotFound(c, utils.MsgUserNotFound)
+		return
+	}
+
+	client, err := newCollabClient(&user)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+
+	relPath := strings.TrimPrefix(fullPath, projectPath+"/")
+	doc, err := joinCollabDoc(&project, projectPath, relPath, client)
+	if err != nil {
+		if errors.Is(err, errCollabUnsupportedFile) {
+			utils.BadRequest(c, utils.MsgCollabUnsupportedFile)
+		} else {
+			utils.InternalServerError(c, utils.MsgFileReadFailed)
+		}
+		return
+	}
+	defer doc.leave(client)
+
+	conn, err := collabUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	client.conn = conn
+	go client.writeLoop()
+
+	conn.SetReadLimit(3*collabMaxFileSize + 4096)
+	conn.SetReadDeadline(time.Now().Add(collabPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(collabPongWait))
+	})
+
+	for {
+		var msg types.CollabMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case "op":
+			var op utils.TextOperation
+			if err := json.Unmarshal(msg.Ops, &op); err != nil {
+				doc.sendError(client, utils.MsgCollabInvalidOperation)
+				continue
+			}
+			doc.submit(client, msg.Version, op)
+		case "cursor":
+			if msg.Anchor != nil && msg.Head != nil {
+				doc.moveCursor(client, *msg.Anchor, *msg.Head)
+			}
+		}
+	}
+}
+
+// GetCollabPresence lists who has which file of a project open
+func GetCollabPresence(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	collabMu.Lock()
+	files := []types.CollabFileResponse{}
+	for _, doc := range collabDocs {
+		if doc.project.ID != project.ID {
+			continue
+		}
+		doc.mu.Lock()
+		files = append(files, types.CollabFileResponse{
+			Path:    doc.relPath,
+			Clients: doc.clientResponses(),
+		})
+		doc.mu.Unlock()
+	}
+	collabMu.Unlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	utils.Success(c, files)
+}
+
+// disconnectCollabEditors saves the open documents of a project and
+// disconnects their editors, before the project moves to another folder
+func disconnectCollabEditors(projectID uint) {
+	collabMu.Lock()
+	var docs []*collabDoc
+	for _, doc := range collabDocs {
+		if doc.project.ID == projectID {
+			docs = append(docs, doc)
+		}
+	}
+	collabMu.Unlock()
+
+	for _, doc := range docs {
+		doc.save()
+		doc.mu.Lock()
+		doc.kickClients()
+		doc.mu.Unlock()
+	}
+}
+
+// newCollabClient creates an editor for a user with a random client ID
+func newCollabClient(user *models.User) (*collabClient, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &collabClient{
+		id:          hex.EncodeToString(id),
+		userID:      user.ID,
+		username:    user.Username,
+		displayName: user.DisplayName,
+		send:        make(chan *types.CollabMessage, 256),
+		kick:        make(chan struct{}),
+	}, nil
+}
+
+// joinCollabDoc adds an editor to the document of a file, opening it if
+// nobody has it open yet, and queues the init message for the editor
+func joinCollabDoc(project *models.Project, projectPath, relPath string, client *collabClient) (*collabDoc, error) {
+	key := fmt.Sprintf("%d:%s", project.ID, relPath)
+
+	collabMu.Lock()
+	for {
+		closing, ok := collabClosing[key]
+		if !ok {
+			break
+		}
+		collabMu.Unlock()
+		<-closing
+		collabMu.Lock()
+	}
+	defer collabMu.Unlock()
+
+	doc, ok := collabDocs[key]
+	if !ok {
+		content, err := storage.Store.Read(path.Join(projectPath, relPath))
+		if err != nil {
+			return nil, err
+		}
+		if !utils.IsTextFile(relPath, content) {
+			return nil, errCollabUnsupportedFile
+		}
+		text := utf16.Encode([]rune(string(content)))
+		if len(text) > collabMaxFileSize {
+			return nil, errCollabUnsupportedFile
+		}
+
+		doc = &collabDoc{
+			key:         key,
+			project:     *project,
+			projectPath: projectPath,
+			relPath:     relPath,
+			content:     text,
+			hash:        services.HashContent(content),
+			clients:     map[string]*collabClient{},
+			done:        make(chan struct{}),
+		}
+		collabDocs[key] = doc
+		go doc.saveLoop()
+	}
+
+	doc.mu.Lock()
+	defer doc.mu.Unlock()
+
+	content := string(utf16.Decode(doc.content))
+	client.trySend(&types.CollabMessage{
+		Type:     "init",
+		ClientID: client.id,
+		Version:  doc.version,
+		Content:  &content,
+		Clients:  doc.clientResponses(),
+	})
+	doc.clients[client.id] = client
+	doc.broadcast(client.id, &types.CollabMessage{Type: "join", Client: client.response()})
+	return doc, nil
+}
+
+// leave removes an editor. When the last editor leaves, the document is
+// closed and saved.
+func (d *collabDoc) leave(client *collabClient) {
+	collabMu.Lock()
+	d.mu.Lock()
+	delete(d.clients, client.id)
+	close(client.send)
+	d.broadcast("", &types.CollabMessage{Type: "leave", Client: client.response()})
+	empty := len(d.clients) == 0
+	d.mu.Unlock()
+
+	if !empty {
+		collabMu.Unlock()
+		return
+	}
+	close(d.done)
+	delete(collabDocs, d.key)
+	closing := make(chan struct{})
+	collabClosing[d.key] = closing
+	collabMu.Unlock()
+
+	// Other documents can be joined and left while this one is written
+	d.save()
+
+	collabMu.Lock()
+	delete(collabClosing, d.key)
+	collabMu.Unlock()
+	close(closing)
+}
+
+// submit applies an operation made by an editor on the given version
+func (d *collabDoc) submit(client *collabClient, version int, op utils.TextOperation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Operations older than the kept history need a fresh copy of the document
+	start := d.version - len(d.history)
+	if version < start || version > d.version {
+		client.trySend(&types.CollabMessage{Type: "error", Message: utils.MsgCollabInvalidOperation})
+		return
+	}
+
+	for _, concurrent := range d.history[version-start:] {
+		var err error
+		if op, _, err = utils.TransformTextOperations(op, concurrent); err != nil {
+			client.trySend(&types.CollabMessage{Type: "error", Message: utils.MsgCollabInvalidOperation})
+			return
+		}
+	}
+
+	content, err := op.Apply(d.content)
+	if err != nil || len(content) > collabMaxFileSize {
+		client.trySend(&types.CollabMessage{Type: "error", Message: utils.MsgCollabInvalidOperation})
+		return
+	}
+
+	d.content = content
+	d.version++
+	d.history = append(d.history, op)
+	if len(d.history) > collabHistorySize {
+		d.history = d.history[len(d.history)-collabHistorySize:]
+	}
+	d.dirty = true
+	d.editor = client.userID
+
+	ops, _ := json.Marshal(op)
+	client.trySend(&types.CollabMessage{Type: "ack", Version: d.version})
+	d.broadcast(client.id, &types.CollabMessage{Type: "op", ClientID: client.id, Version: d.version, Ops: ops})
+}
+
+// sendError sends an error message code to an editor
+func (d *collabDoc) sendError(client *collabClient, msgCode string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	client.trySend(&types.CollabMessage{Type: "error", Version: d.version, Message: msgCode})
+}
+
+// moveCursor records and relays the selection of an editor
+func (d *collabDoc) moveCursor(client *collabClient, anchor, head int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	client.anchor, client.head = anchor, head
+	d.broadcast(client.id, &types.CollabMessage{Type: "cursor", Version: d.version, Client: client.response()})
+}
+
+// saveLoop saves the document periodically until it is closed
+func (d *collabDoc) saveLoop() {
+	ticker := time.NewTicker(collabSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.save()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// save writes the document to the project if it changed since the last save
+func (d *collabDoc) save() {
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+
+	d.mu.Lock()
+	if !d.dirty {
+		d.mu.Unlock()
+		return
+	}
+	content := []byte(string(utf16.Decode(d.content)))
+	version, editor, hash := d.version, d.editor, d.hash
+	d.mu.Unlock()
+
+	stored, msgCode := d.persist(content, hash, editor)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if msgCode == utils.MsgCollabFileChanged {
+		d.reload(stored)
+		return
+	}
+	if msgCode != "" {
+		d.broadcast("", &types.CollabMessage{Type: "error", Version: version, Message: msgCode})
+		return
+	}
+	d.hash = services.HashContent(content)
+	if d.version == version {
+		d.dirty = false
+	}
+	d.broadcast("", &types.CollabMessage{Type: "saved", Version: version, Hash: services.HashContent(content)})
+}
+
+// persist writes content through the editor save path, as long as the
+// stored file still has the given hash, and returns the message code of the
+// failure, if any. If the file changed, it returns its stored content with
+// MsgCollabFileChanged.
+func (d *collabDoc) persist(content []byte, hash string, userID uint) ([]byte, string) {
+	unlock := lockFileSaves(d.project.ID)
+	defer unlock()
+
+	// Files moved or deleted in the meantime are not recreated
+	previous, err := storage.Store.Read(path.Join(d.projectPath, d.relPath))
+	if err != nil {
+		return nil, utils.MsgFileNotFound
+	}
+
+	// Saves made some other way are not overwritten
+	if services.HashContent(previous) != hash {
+		return previous, utils.MsgCollabFileChanged
+	}
+
+	if err := services.CheckQuota(&d.project.User, int64(len(content)-len(previous)), 0); err != nil {
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			return nil, utils.MsgQuotaExceeded
+		}
+		return nil, utils.MsgInternalError
+	}
+
+	if _, err := services.CheckContentPolicy(&d.project.User, d.relPath, int64(len(content)), bytes.NewReader(content)); err != nil {
+		var policyErr *services.ContentPolicyError
+		if errors.As(err, &policyErr) {
+			return nil, utils.MsgContentPolicyViolation
+		}
+		return nil, utils.MsgInternalError
+	}
+
+	if err := saveFileContent(&d.project, d.projectPath, d.relPath, previous, content, userID); err != nil {
+		log.Printf("Failed to save collaborative edits of %s: %v", d.relPath, err)
+		return nil, utils.MsgFileWriteFailed
+	}
+	return nil, ""
+}
+
+// reload replaces the document with the stored content of the file and
+// sends it to every editor. Files that can no longer be edited together
+// disconnect the editors instead. d.mu must be held.
+func (d *collabDoc) reload(stored []byte) {
+	text := utf16.Encode([]rune(string(stored)))
+	if !utils.IsTextFile(d.relPath, stored) || len(text) > collabMaxFileSize {
+		d.kickClients()
+		return
+	}
+
+	// Operations made on the dropped edits can no longer be transformed
+	d.content = text
+	d.version++
+	d.history = nil
+	d.dirty = false
+	d.hash = services.HashContent(stored)
+
+	content := string(utf16.Decode(d.content))
+	clients := d.clientResponses()
+	for _, client := range d.clients {
+		client.trySend(&types.CollabMessage{
+			Type:     "init",
+			ClientID: client.id,
+			Version:  d.version,
+			Content:  &content,
+			Clients:  clients,
+			Message:  utils.MsgCollabFileChanged,
+		})
+	}
+}
+
+// kickClients disconnects every editor. d.mu must be held.
+func (d *collabDoc) kickClients() {
+	for _, client := range d.clients {
+		if !client.kicked {
+			client.kicked = true
+			close(client.kick)
+		}
+	}
+}
+
+// broadcast queues a message for every editor except the one with skipID.
+// d.mu must be held.
+func (d *collabDoc) broadcast(skipID string, msg *types.CollabMessage) {
+	for id, client := range d.clients {
+		if id != skipID {
+			client.trySend(msg)
+		}
+	}
+}
+
+// clientResponses describes the editors of the document. d.mu must be held.
+func (d *collabDoc) clientResponses() []types.CollabClientResponse {
+	clients := []types.CollabClientResponse{}
+	for _, client := range d.clients {
+		clients = append(clients, *client.response())
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ClientID < clients[j].ClientID
+	})
+	return clients
+}
+
+func (cl *collabClient) response() *types.CollabClientResponse {
+	return &types.CollabClientResponse{
+		ClientID:    cl.id,
+		UserID:      cl.userID,
+		Username:    cl.username,
+		DisplayName: cl.displayName,
+		Anchor:      cl.anchor,
+		Head:        cl.head,
+	}
+}
+
+// trySend queues a message without blocking. Editors that cannot keep up
+// are disconnected, since a dropped operation would corrupt their copy.
+// The document's mu must be held.
+func (cl *collabClient) trySend(msg *types.CollabMessage) {
+	select {
+	case cl.send <- msg:
+	default:
+		if !cl.kicked {
+			cl.kicked = true
+			close(cl.kick)
+		}
+	}
+}
+
+// writeLoop writes queued messages and pings to the editor until its
+// queue is closed
+func (cl *collabClient) writeLoop() {
+	ticker := time.NewTicker(collabPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-cl.send:
+			cl.conn.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if !ok {
+				cl.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := cl.conn.WriteJSON(msg); err != nil {
+				cl.conn.Close()
+				return
+			}
+		case <-cl.kick:
+			cl.conn.Close()
+			return
+		case <-ticker.C:
+			cl.conn.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if err := cl.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				cl.conn.Close()
+				return
+			}
+		}
+	}
+}
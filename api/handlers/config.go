@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/services"
 	"github.com/itsHenry35/StaticForge/types"
 	"github.com/itsHenry35/StaticForge/utils"
 )
@@ -13,13 +14,18 @@ import (
 func GetPublicConfig(c *gin.Context) {
 	cfg := config.GetConfig()
 
-	utils.Success(c, types.PublicConfigResponse{
+	response := types.PublicConfigResponse{
 		AllowRegister: cfg.AllowRegister,
 		LogoURL:       cfg.LogoURL,
 		SiteName:      cfg.SiteName,
 		SiteHost:      cfg.SiteHost,
 		SecureHost:    cfg.SecureHost,
-	})
+	}
+	if cfg.SFTP.Enabled {
+		response.SFTPPort = cfg.SFTP.Port
+	}
+
+	utils.Success(c, response)
 }
 
 // GetConfig returns full configuration (admin only)
@@ -59,6 +65,16 @@ func GetConfig(c *gin.Context) {
 		SiteName:            cfg.SiteName,
 		SiteHost:            cfg.SiteHost,
 		SecureHost:          cfg.SecureHost,
+		Quota: types.QuotaConfig{
+			Normal:   types.QuotaLimit(cfg.Quota.Normal),
+			Verified: types.QuotaLimit(cfg.Quota.Verified),
+			Admin:    types.QuotaLimit(cfg.Quota.Admin),
+		},
+		ContentPolicy: types.ContentPolicyConfig{
+			Normal:   toContentPolicy(cfg.ContentPolicy.Normal),
+			Verified: toContentPolicy(cfg.ContentPolicy.Verified),
+			Admin:    toContentPolicy(cfg.ContentPolicy.Admin),
+		},
 	})
 }
 
@@ -70,6 +86,21 @@ func UpdateConfig(c *gin.Context) {
 		return
 	}
 
+	var contentPolicy *config.ContentPolicyConfig
+	if req.ContentPolicy != nil {
+		contentPolicy = &config.ContentPolicyConfig{
+			Normal:   fromContentPolicy(req.ContentPolicy.Normal),
+			Verified: fromContentPolicy(req.ContentPolicy.Verified),
+			Admin:    fromContentPolicy(req.ContentPolicy.Admin),
+		}
+		for _, policy := range []config.ContentPolicy{contentPolicy.Normal, contentPolicy.Verified, contentPolicy.Admin} {
+			if err := services.ValidateContentPolicy(policy); err != nil {
+				utils.BadRequest(c, utils.MsgInvalidContentPolicy)
+				return
+			}
+		}
+	}
+
 	cfg := config.GetConfig()
 
 	// Update all config fields
@@ -106,6 +137,20 @@ func UpdateConfig(c *gin.Context) {
 		})
 	}
 
+	// Update quotas
+	if req.Quota != nil {
+		cfg.Quota = config.QuotaConfig{
+			Normal:   config.QuotaLimit(req.Quota.Normal),
+			Verified: config.QuotaLimit(req.Quota.Verified),
+			Admin:    config.QuotaLimit(req.Quota.Admin),
+		}
+	}
+
+	// Update content policies
+	if contentPolicy != nil {
+		cfg.ContentPolicy = *contentPolicy
+	}
+
 	// Discover OIDC endpoints for new providers (non-fatal: log and continue)
 	if err := cfg.InitializeOAuth(); err != nil {
 		log.Printf("Warning: OIDC discovery failed: %v", err)
@@ -119,3 +164,35 @@ func UpdateConfig(c *gin.Context) {
 
 	utils.SuccessWithCode(c, utils.MsgConfigUpdated, nil)
 }
+
+// toContentPolicy converts a content policy for the API
+func toContentPolicy(policy config.ContentPolicy) types.ContentPolicy {
+	rules := []types.ContentRule{}
+	for _, rule := range policy.ContentRules {
+		rules = append(rules, types.ContentRule(rule))
+	}
+	return types.ContentPolicy{
+		AllowedExtensions: policy.AllowedExtensions,
+		DeniedExtensions:  policy.DeniedExtensions,
+		VerifyMimeType:    policy.VerifyMimeType,
+		MaxSizes:          policy.MaxSizes,
+		XSSCheck:          policy.XSSCheck,
+		ContentRules:      rules,
+	}
+}
+
+// fromContentPolicy converts a content policy from the API
+func fromContentPolicy(policy types.ContentPolicy) config.ContentPolicy {
+	var rules []config.ContentRule
+	for _, rule := range policy.ContentRules {
+		rules = append(rules, config.ContentRule(rule))
+	}
+	return config.ContentPolicy{
+		AllowedExtensions: policy.AllowedExtensions,
+		DeniedExtensions:  policy.DeniedExtensions,
+		VerifyMimeType:    policy.VerifyMimeType,
+		MaxSizes:          policy.MaxSizes,
+		XSSCheck:          policy.XSSCheck,
+		ContentRules:      rules,
+	}
+}
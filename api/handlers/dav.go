@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+	"golang.org/x/net/webdav"
+)
+
+// Projects are mounted over WebDAV at /dav/{name}/. Clients authenticate as
+// described for authorizeClientRequest, including account passwords, since
+// desktop clients can only send basic auth. The same rules as the file API
+// apply: files count towards the owner's quota, deleted files go to the
+// trash, text edits are kept in the file history and the root index.html
+// cannot be deleted or moved.
+
+// davLocks holds the WebDAV lock system of each project
+var davLocks sync.Map
+
+// DAVMethods are the HTTP methods served by the WebDAV handler
+var DAVMethods = []string{
+	"OPTIONS", "GET", "HEAD", "POST", "PUT", "DELETE",
+	"MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK", "PROPFIND", "PROPPATCH",
+}
+
+// ServeWebDAV serves the files of a project over WebDAV
+func ServeWebDAV(c *gin.Context) {
+	project, userID, ok := authorizeClientRequest(c, c.Param("project"), true)
+	if !ok {
+		return
+	}
+
+	davFS := &projectDAVFS{
+		project: project,
+		root:    project.GetStoragePath(project.User.Username),
+		userID:  userID,
+	}
+
+	// Reject uploads that are too large before reading them
+	if c.Request.Method == http.MethodPut && c.Request.ContentLength > 0 {
+		if c.Request.ContentLength > config.GetConfig().Upload.MaxSize {
+			c.String(http.StatusRequestEntityTooLarge, "file exceeds maximum size")
+			return
+		}
+		addBytes, addFiles := c.Request.ContentLength, int64(1)
+		if fullPath, ok := davFS.resolve(strings.TrimPrefix(c.Param("path"), "/")); ok {
+			if info, err := storage.Store.Stat(fullPath); err == nil && !info.IsDir {
+				addBytes, addFiles = addBytes-info.Size, 0
+			}
+			if _, err := services.CheckContentPolicy(&project.User, davFS.relPath(fullPath), c.Request.ContentLength, nil); err != nil {
+				c.String(http.StatusForbidden, "file not allowed by the content policy")
+				return
+			}
+		}
+		if err := services.CheckQuota(&project.User, addBytes, addFiles); err != nil {
+			if errors.Is(err, services.ErrQuotaExceeded) {
+				c.String(http.StatusInsufficientStorage, "storage quota exceeded")
+			} else {
+				c.String(http.StatusInternalServerError, "failed to check quota")
+			}
+			return
+		}
+	}
+
+	locks, _ := davLocks.LoadOrStore(project.ID, webdav.NewMemLS())
+	handler := &webdav.Handler{
+		Prefix:     "/dav/" + project.Name,
+		FileSystem: davFS,
+		LockSystem: locks.(webdav.LockSystem),
+	}
+	handler.ServeHTTP(c.Writer, c.Request)
+}
+
+// projectDAVFS is a webdav.FileSystem over the files of a project
+type projectDAVFS struct {
+	project *models.Project
+	root    string // storage path of the project
+	userID  uint   // user changes are attributed to
+}
+
+// resolve returns the storage path of a WebDAV name, or false if it is
+// outside the project
+func (f *projectDAVFS) resolve(name string) (string, bool) {
+	fullPath := path.Join(f.root, name)
+	return fullPath, isPathSafe(fullPath, f.root)
+}
+
+// relPath returns the project-relative path of a storage path
+func (f *projectDAVFS) relPath(fullPath string) string {
+	return strings.TrimPrefix(fullPath, f.root+"/")
+}
+
+func (f *projectDAVFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	fullPath, ok := f.resolve(name)
+	if !ok || fullPath == f.root {
+		return os.ErrPermission
+	}
+	if storage.Exists(storage.Store, fullPath) {
+		return os.ErrExist
+	}
+	// Like mkdir, only the last element is created
+	if parent, err := storage.Store.Stat(path.Dir(fullPath)); err != nil || !parent.IsDir {
+		return os.ErrNotExist
+	}
+	return storage.Store.Mkdir(fullPath)
+}
+
+func (f *projectDAVFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	fullPath, ok := f.resolve(name)
+	if !ok {
+		return nil, os.ErrPermission
+	}
+
+	info, err := storage.Store.Stat(fullPath)
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return nil, err
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		if info == nil {
+			return nil, os.ErrNotExist
+		}
+		if info.IsDir {
+			return &davDir{fs: f, fullPath: fullPath, info: info}, nil
+		}
+		file, err := storage.Store.Open(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		return &davReadFile{File: file, info: info}, nil
+	}
+
+	// Writes are staged in a temporary file and stored on Close
+	if info != nil && (info.IsDir || flag&os.O_EXCL != 0) {
+		return nil, os.ErrExist
+	}
+	if info == nil && flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+	if parent, err := storage.Store.Stat(path.Dir(fullPath)); err != nil || !parent.IsDir {
+		return nil, os.ErrNotExist
+	}
+
+	tmp, err := os.CreateTemp("", "staticforge-dav-*")
+	if err != nil {
+		return nil, err
+	}
+	return &davWriteFile{fs: f, fullPath: fullPath, tmp: tmp}, nil
+}
+
+func (f *projectDAVFS) RemoveAll(ctx context.Context, name string) error {
+	fullPath, ok := f.resolve(name)
+	if !ok || fullPath == f.root {
+		return os.ErrPermission
+	}
+
+	// Prevent deleting index.html
+	relPath := f.relPath(fullPath)
+	if relPath == "index.html" {
+		return os.ErrPermission
+	}
+	if !storage.Exists(storage.Store, fullPath) {
+		return os.ErrNotExist
+	}
+
+	// Keep the last content in the file history and move it to the trash
+	if err := services.RecordDeletedRevisions(f.project.ID, f.root, relPath, f.userID); err != nil {
+		return err
+	}
+	_, err := services.MoveToTrash(f.project.ID, f.root, relPath, f.userID)
+	return err
+}
+
+func (f *projectDAVFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldPath, ok := f.resolve(oldName)
+	if !ok || oldPath == f.root {
+		return os.ErrPermission
+	}
+	newPath, ok := f.resolve(newName)
+	if !ok || newPath == f.root || strings.HasPrefix(newPath, oldPath+"/") {
+		return os.ErrPermission
+	}
+
+	// Prevent moving index.html, or replacing it with a folder
+	oldRel, newRel := f.relPath(oldPath), f.relPath(newPath)
+	if oldRel == "index.html" {
+		return os.ErrPermission
+	}
+	info, err := storage.Store.Stat(oldPath)
+	if err != nil {
+		return err
+	}
+	if newRel == "index.html" && info.IsDir {
+		return os.ErrPermission
+	}
+	if parent, err := storage.Store.Stat(path.Dir(newPath)); err != nil || !parent.IsDir {
+		return os.ErrNotExist
+	}
+
+	// A file getting another extension must pass the owner's content policy
+	if !info.IsDir && !strings.EqualFold(path.Ext(oldRel), path.Ext(newRel)) {
+		if _, err := services.CheckStoredContentPolicy(&f.project.User, oldPath, newRel); err != nil {
+			return os.ErrPermission
+		}
+	}
+
+	if err := storage.Store.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	services.PublishFileChange(f.project.ID, services.FileChange{Type: services.FileChangeMove, Path: newRel, OldPath: oldRel})
+
+	// Keep the file history with the moved files
+	if err := services.MoveRevisions(f.project.ID, oldRel, newRel); err != nil {
+		log.Printf("Failed to move revisions of %s: %v", oldRel, err)
+	}
+	return nil
+}
+
+func (f *projectDAVFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	fullPath, ok := f.resolve(name)
+	if !ok {
+		return nil, os.ErrPermission
+	}
+	info, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	return davFileInfo{info}, nil
+}
+
+// davFileInfo adapts a storage.FileInfo to os.FileInfo
+type davFileInfo struct {
+	info *storage.FileInfo
+}
+
+func (i davFileInfo) Name() string       { return i.info.Name }
+func (i davFileInfo) Size() int64        { return i.info.Size }
+func (i davFileInfo) ModTime() time.Time { return i.info.ModTime }
+func (i davFileInfo) IsDir() bool        { return i.info.IsDir }
+func (i davFileInfo) Sys() any           { return nil }
+
+func (i davFileInfo) Mode() os.FileMode {
+	if i.info.IsDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// ContentType avoids reading files to detect their type in PROPFIND
+func (i davFileInfo) ContentType(ctx context.Context) (string, error) {
+	return utils.GetMimeType(i.info.Name), nil
+}
+
+// davReadFile is a project file opened for reading
+type davReadFile struct {
+	storage.File
+	info *storage.FileInfo
+}
+
+func (f *davReadFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *davReadFile) Stat() (fs.FileInfo, error) {
+	return davFileInfo{f.info}, nil
+}
+
+func (f *davReadFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// davDir is a project folder opened for listing
+type davDir struct {
+	fs       *projectDAVFS
+	fullPath string
+	info     *storage.FileInfo
+	entries  []fs.FileInfo
+	listed   bool
+}
+
+func (d *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.listed {
+		entries, err := storage.Store.List(d.fullPath)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			// List is recursive; only direct children belong to this folder
+			if !strings.Contains(entries[i].Path, "/") {
+				d.entries = append(d.entries, davFileInfo{&entries[i]})
+			}
+		}
+		d.listed = true
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *davDir) Stat() (fs.FileInfo, error)     { return davFileInfo{d.info}, nil }
+func (d *davDir) Read(p []byte) (int, error)     { return 0, os.ErrInvalid }
+func (d *davDir) Seek(int64, int) (int64, error) { return 0, os.ErrInvalid }
+func (d *davDir) Write(p []byte) (int, error)    { return 0, os.ErrInvalid }
+func (d *davDir) Close() error                   { return nil }
+
+// davWriteFile is a project file opened for writing. Content is staged in a
+// local temporary file and checked against the size limit and quota before
+// it replaces the project file on Close.
+type davWriteFile struct {
+	fs       *projectDAVFS
+	fullPath string
+	tmp      *os.File
+	size     int64
+}
+
+func (f *davWriteFile) Write(p []byte) (int, error) {
+	if f.size+int64(len(p)) > config.GetConfig().Upload.MaxSize {
+		return 0, errors.New("file exceeds maximum size")
+	}
+	n, err := f.tmp.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *davWriteFile) Read(p []byte) (int, error) {
+	return f.tmp.Read(p)
+}
+
+func (f *davWriteFile) Seek(offset int64, whence int) (int64, error) {
+	return f.tmp.Seek(offset, whence)
+}
+
+func (f *davWriteFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *davWriteFile) Stat() (fs.FileInfo, error) {
+	return davFileInfo{&storage.FileInfo{
+		Name:    path.Base(f.fullPath),
+		Size:    f.size,
+		ModTime: time.Now(),
+	}}, nil
+}
+
+func (f *davWriteFile) Close() error {
+	defer os.Remove(f.tmp.Name())
+	defer f.tmp.Close()
+
+	return services.SaveProjectFile(f.fs.project, f.fs.root, f.fs.relPath(f.fullPath), f.tmp, f.size, f.fs.userID)
+}
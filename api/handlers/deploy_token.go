@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// GetDeployTokens lists the deploy tokens of a project
+func GetDeployTokens(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var tokens []models.DeployToken
+	if err := database.DB.Where("project_id = ?", project.ID).Order("id DESC").Find(&tokens).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	tokenResponses := []types.DeployTokenResponse{}
+	for _, token := range tokens {
+		tokenResponses = append(tokenResponses, toDeployTokenResponse(&token))
+	}
+
+	utils.Success(c, tokenResponses)
+}
+
+// CreateDeployToken creates a deploy token for pushing to a project's git
+// repository. The token is only returned in this response.
+func CreateDeployToken(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req types.CreateDeployTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	token, secret, err := services.CreateDeployToken(project.ID, req.Name, userID.(uint))
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	response := toDeployTokenResponse(token)
+	response.Token = secret
+	utils.SuccessWithCode(c, utils.MsgDeployTokenCreated, response)
+}
+
+// DeleteDeployToken revokes a deploy token
+func DeleteDeployToken(c *gin.Context) {
+	projectID := c.Param("id")
+	tokenID := c.Param("token_id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var token models.DeployToken
+	if err := database.DB.Where("project_id = ?", project.ID).First(&token, tokenID).Error; err != nil {
+		utils.NotFound(c, utils.MsgDeployTokenNotFound)
+		return
+	}
+
+	if err := database.DB.Delete(&token).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgDeployTokenDeleted, nil)
+}
+
+// toDeployTokenResponse converts a deploy token to its API representation
+func toDeployTokenResponse(token *models.DeployToken) types.DeployTokenResponse {
+	return types.DeployTokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		CreatedBy:  token.CreatedBy,
+		CreatedAt:  token.CreatedAt.Format(time.RFC3339),
+		LastUsedAt: formatOptionalTime(token.LastUsedAt),
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// GetProjectDeployments lists all deployments of a project, newest first
+func GetProjectDeployments(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var deployments []models.Deployment
+	if err := database.DB.Where("project_id = ?", project.ID).Order("number DESC").Find(&deployments).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	deploymentResponses := []types.DeploymentResponse{}
+	for _, deployment := range deployments {
+		deploymentResponses = append(deploymentResponses, toDeploymentResponse(&project, &deployment))
+	}
+
+	utils.Success(c, deploymentResponses)
+}
+
+// RollbackDeployment makes a past deployment the active one
+func RollbackDeployment(c *gin.Context) {
+	projectID := c.Param("id")
+	deploymentID := c.Param("deployment_id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var deployment models.Deployment
+	if err := database.DB.Where("project_id = ?", project.ID).First(&deployment, deploymentID).Error; err != nil {
+		utils.NotFound(c, utils.MsgDeploymentNotFound)
+		return
+	}
+
+	if err := services.ActivateDeployment(&project, &deployment); err != nil {
+		utils.InternalServerError(c, utils.MsgDeploymentRollbackFailed)
+		return
+	}
+	project.ActiveDeploymentID = &deployment.ID
+
+	utils.SuccessWithCode(c, utils.MsgDeploymentRolledBack, toDeploymentResponse(&project, &deployment))
+}
+
+// toDeploymentResponse converts a deployment model to its API representation
+func toDeploymentResponse(project *models.Project, deployment *models.Deployment) types.DeploymentResponse {
+	return types.DeploymentResponse{
+		ID:        deployment.ID,
+		Number:    deployment.Number,
+		CreatedBy: deployment.CreatedBy,
+		FileCount: deployment.FileCount,
+		TotalSize: deployment.TotalSize,
+		IsActive:  project.ActiveDeploymentID != nil && *project.ActiveDeploymentID == deployment.ID,
+		CreatedAt: deployment.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// ExportProject streams all project files as a ZIP archive. With
+// ?apply_replacements=true the configured replacement rules are applied to
+// HTML/CSS/JS files, producing a bundle deployable on any static host.
+func ExportProject(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+	applyReplacements := c.Query("apply_replacements") == "true"
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	projectPath := project.GetStoragePath(project.User.Username)
+	entries, err := storage.Store.List(projectPath)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, project.Name))
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	// Errors past this point can no longer be reported as JSON,
+	// so the archive is cut short and the failure is logged
+	cfg := config.GetConfig()
+	zw := zip.NewWriter(c.Writer)
+	for _, entry := range entries {
+		if err := writeExportEntry(zw, cfg, projectPath, entry, applyReplacements); err != nil {
+			log.Printf("Failed to export %s of project %d: %v", entry.Path, project.ID, err)
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		log.Printf("Failed to finish export of project %d: %v", project.ID, err)
+	}
+}
+
+// writeExportEntry adds a single project file or folder to the archive
+func writeExportEntry(zw *zip.Writer, cfg *config.Config, projectPath string, entry storage.FileInfo, applyReplacements bool) error {
+	if entry.IsDir {
+		_, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     entry.Path + "/",
+			Modified: entry.ModTime,
+		})
+		return err
+	}
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     entry.Path,
+		Method:   zip.Deflate,
+		Modified: entry.ModTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	fullPath := path.Join(projectPath, entry.Path)
+	if applyReplacements && hasReplacements(fullPath) {
+		content, err := storage.Store.Read(fullPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, cfg.ApplyReplacements(string(content)))
+		return err
+	}
+
+	f, err := storage.Store.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -1,24 +1,36 @@
 package handlers
 
 import (
-	"os"
-	"path/filepath"
+	"io"
+	"net/http"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/itsHenry35/StaticForge/config"
 	"github.com/itsHenry35/StaticForge/database"
 	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
 	"github.com/itsHenry35/StaticForge/types"
 	"github.com/itsHenry35/StaticForge/utils"
 )
 
+// multipartOverhead is the room left for multipart headers and form fields
+// on top of Upload.MaxSize when limiting upload request bodies
+const multipartOverhead = 1 << 20
+
 // UploadFile uploads a file to project
 func UploadFile(c *gin.Context) {
 	projectID := c.Param("id")
 	userID, _ := c.Get("user_id")
 	isAdmin, _ := c.Get("is_admin")
 
+	// Reject oversized bodies while they are received instead of afterwards
+	cfg := config.GetConfig()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.Upload.MaxSize+multipartOverhead)
+
 	// Get file from form
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -27,14 +39,13 @@ func UploadFile(c *gin.Context) {
 	}
 
 	// Get path from form
-	path := c.PostForm("path")
-	if path == "" {
-		path = "/"
+	dir := c.PostForm("path")
+	if dir == "" {
+		dir = "/"
 	}
 	overwrite := c.PostForm("overwrite") == "true"
 
 	// Validate file size
-	cfg := config.GetConfig()
 	if file.Size > cfg.Upload.MaxSize {
 		utils.BadRequest(c, utils.MsgInvalidRequest)
 		return
@@ -55,49 +66,243 @@ func UploadFile(c *gin.Context) {
 
 	// Sanitize filename
 	filename := utils.SanitizeFilename(file.Filename)
-	relativePath := filepath.Join(path, filename)
-	relativePath = filepath.ToSlash(relativePath)
+	relativePath := path.Join(dir, filename)
 
-	// Save file to disk
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	fullPath := filepath.Join(projectPath, relativePath)
+	// Save file to storage
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, relativePath)
+
+	// Security check
+	if !isPathSafe(fullPath, projectPath) {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
+		return
+	}
 
-	// Check if file already exists
-	if _, err := os.Stat(fullPath); err == nil {
-		if !overwrite {
+	// Check if file already exists (an existing file is replaced in place)
+	addBytes, addFiles := file.Size, int64(1)
+	if existing, err := storage.Store.Stat(fullPath); err == nil {
+		if !overwrite || existing.IsDir {
 			utils.BadRequest(c, utils.MsgInvalidRequest)
 			return
 		}
-		// Overwrite: remove existing file first
-		if err := os.Remove(fullPath); err != nil {
-			utils.InternalServerError(c, utils.MsgFileUploadFailed)
-			return
-		}
+		addBytes, addFiles = file.Size-existing.Size, 0
+	}
+
+	// Check the owner's quota
+	if !checkQuota(c, &project.User, addBytes, addFiles) {
+		return
 	}
 
-	// Ensure parent directory exists
-	parentDir := filepath.Dir(fullPath)
-	if err := utils.EnsureDir(parentDir); err != nil {
+	src, err := file.Open()
+	if err != nil {
 		utils.InternalServerError(c, utils.MsgFileUploadFailed)
 		return
 	}
+	defer src.Close()
 
-	if err := utils.SaveUploadedFile(file, fullPath); err != nil {
+	// Check the owner's content policy, then rewind for the write
+	warnings, ok := checkContentPolicy(c, &project.User, relativePath, file.Size, src)
+	if !ok {
+		return
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		utils.InternalServerError(c, utils.MsgFileUploadFailed)
+		return
+	}
+
+	if err := storage.Store.Write(fullPath, src, file.Size); err != nil {
 		utils.InternalServerError(c, utils.MsgFileUploadFailed)
 		return
 	}
+	services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeWrite, Path: relativePath})
 
 	// Get file info
-	fileInfo, _ := os.Stat(fullPath)
+	fileInfo, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileUploadFailed)
+		return
+	}
 
-	utils.SuccessWithCode(c, utils.MsgFileUploaded, map[string]interface{}{
+	result := map[string]interface{}{
 		"path":       relativePath,
 		"name":       filename,
 		"size":       file.Size,
 		"mime_type":  utils.GetMimeType(filename),
 		"is_folder":  false,
-		"updated_at": fileInfo.ModTime().Format(time.RFC3339),
-	})
+		"updated_at": fileInfo.ModTime.Format(time.RFC3339),
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+	utils.SuccessWithCode(c, utils.MsgFileUploaded, result)
+}
+
+// UploadFiles uploads several files in one request, keeping their relative
+// paths so whole folders can be uploaded. Each "files" part may be paired with
+// a "paths" value (in the same order) holding its path relative to the target
+// folder, e.g. the browser's webkitRelativePath. Missing folders are created
+// and every file gets its own result; the request as a whole is limited to
+// Upload.MaxSize.
+func UploadFiles(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Reject oversized bodies while they are received instead of afterwards
+	cfg := config.GetConfig()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.Upload.MaxSize+multipartOverhead)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+	files := form.File["files"]
+	relPaths := form.Value["paths"]
+	if len(files) == 0 {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	dir := c.PostForm("path")
+	if dir == "" {
+		dir = "/"
+	}
+	overwrite := c.PostForm("overwrite") == "true"
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	projectPath := project.GetStoragePath(project.User.Username)
+
+	// Resolve and validate every target before writing anything
+	type uploadTarget struct {
+		fullPath string
+		existing *storage.FileInfo
+	}
+	results := make([]types.UploadFileResult, len(files))
+	targets := make([]*uploadTarget, len(files))
+	seen := make(map[string]bool)
+	var addBytes, addFiles int64
+	for i, file := range files {
+		name := file.Filename
+		if i < len(relPaths) && relPaths[i] != "" {
+			name = relPaths[i]
+		}
+		relativePath := sanitizeRelativePath(name)
+		fullPath := path.Join(projectPath, dir, relativePath)
+		results[i] = types.UploadFileResult{
+			Path: strings.TrimPrefix(fullPath, projectPath+"/"),
+			Size: file.Size,
+		}
+
+		// Security check
+		if relativePath == "" || !isPathSafe(fullPath, projectPath) || fullPath == projectPath {
+			results[i].Status, results[i].Reason = "skipped", "unsafe_path"
+			continue
+		}
+
+		if file.Size > cfg.Upload.MaxSize {
+			results[i].Status, results[i].Reason = "skipped", "too_large"
+			continue
+		}
+
+		// Existing files are only replaced when overwrite is set, and a file
+		// cannot take the place of a folder or sit below another file
+		existing, err := storage.Store.Stat(fullPath)
+		if seen[fullPath] || (err == nil && (!overwrite || existing.IsDir)) {
+			results[i].Status, results[i].Reason = "skipped", "conflict"
+			continue
+		}
+		if parent, err := storage.Store.Stat(path.Dir(fullPath)); err == nil && !parent.IsDir {
+			results[i].Status, results[i].Reason = "skipped", "conflict"
+			continue
+		}
+
+		seen[fullPath] = true
+		targets[i] = &uploadTarget{fullPath: fullPath}
+		if existing != nil {
+			targets[i].existing = existing
+			addBytes += file.Size - existing.Size
+		} else {
+			addBytes += file.Size
+			addFiles++
+		}
+	}
+
+	// Check the owner's quota against everything that will be written
+	if !checkQuota(c, &project.User, addBytes, addFiles) {
+		return
+	}
+
+	for i, file := range files {
+		target := targets[i]
+		if target == nil {
+			continue
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			results[i].Status, results[i].Reason = "skipped", "write_failed"
+			continue
+		}
+
+		// Files breaking the owner's content policy are skipped
+		warnings, err := services.CheckContentPolicy(&project.User, results[i].Path, file.Size, src)
+		if err != nil {
+			src.Close()
+			results[i].Status, results[i].Violations = "skipped", policyViolations(err)
+			if results[i].Violations != nil {
+				results[i].Reason = "content_policy"
+			} else {
+				results[i].Reason = "write_failed"
+			}
+			continue
+		}
+		results[i].Violations = toPolicyViolations(warnings)
+
+		if _, err = src.Seek(0, io.SeekStart); err == nil {
+			err = storage.Store.Write(target.fullPath, src, file.Size)
+		}
+		src.Close()
+		if err != nil {
+			results[i].Status, results[i].Reason = "skipped", "write_failed"
+			continue
+		}
+		services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeWrite, Path: strings.TrimPrefix(target.fullPath, projectPath+"/")})
+
+		if target.existing != nil {
+			results[i].Status = "overwritten"
+		} else {
+			results[i].Status = "uploaded"
+		}
+	}
+
+	utils.SuccessWithCode(c, utils.MsgFilesUploaded, results)
+}
+
+// sanitizeRelativePath sanitizes every segment of a client supplied relative
+// path, dropping empty and traversal segments
+func sanitizeRelativePath(name string) string {
+	var segments []string
+	for _, segment := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		segment = utils.SanitizeFilename(segment)
+		if segment == "" || segment == "." {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	return strings.Join(segments, "/")
 }
 
 // CreateFolder creates a new folder
@@ -129,27 +334,35 @@ func CreateFolder(c *gin.Context) {
 	}
 
 	// Calculate folder path
-	folderPath := filepath.Join(req.Path, folderName)
-	folderPath = filepath.ToSlash(folderPath)
+	folderPath := path.Join(req.Path, folderName)
 
-	// Create folder on disk
-	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	fullPath := filepath.Join(projectPath, folderPath)
+	// Create folder in storage
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, folderPath)
+
+	// Security check
+	if !isPathSafe(fullPath, projectPath) {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
+		return
+	}
 
 	// Check if folder already exists
-	if _, err := os.Stat(fullPath); err == nil {
+	if _, err := storage.Store.Stat(fullPath); err == nil {
 		utils.BadRequest(c, utils.MsgInvalidRequest)
 		return
 	}
 
-	if err := utils.EnsureDir(fullPath); err != nil {
+	if err := storage.Store.Mkdir(fullPath); err != nil {
 		utils.InternalServerError(c, utils.MsgDirectoryCreationFailed)
 		return
 	}
 
 	// Get folder info
-	folderInfo, _ := os.Stat(fullPath)
+	folderInfo, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgDirectoryCreationFailed)
+		return
+	}
 
 	utils.SuccessWithCode(c, utils.MsgDirectoryCreated, map[string]interface{}{
 		"path":       folderPath,
@@ -157,6 +370,6 @@ func CreateFolder(c *gin.Context) {
 		"size":       int64(0),
 		"mime_type":  "",
 		"is_folder":  true,
-		"updated_at": folderInfo.ModTime().Format(time.RFC3339),
+		"updated_at": folderInfo.ModTime.Format(time.RFC3339),
 	})
 }
@@ -1,15 +1,21 @@
 package handlers
 
 import (
-	"io/fs"
-	"os"
-	"path/filepath"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/itsHenry35/StaticForge/config"
 	"github.com/itsHenry35/StaticForge/database"
 	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
 	"github.com/itsHenry35/StaticForge/utils"
 )
 
@@ -42,67 +48,45 @@ func ScanProjectFiles(c *gin.Context) {
 		return
 	}
 
-	// Get project path
-	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-
 	// Scan directory
-	files := []FileInfo{}
-	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip the root directory itself
-		if path == projectPath {
-			return nil
-		}
-
-		// Get relative path
-		relPath, err := filepath.Rel(projectPath, path)
-		if err != nil {
-			return err
-		}
-		relPath = filepath.ToSlash(relPath)
-
-		// Get file info
-		info, err := d.Info()
-		if err != nil {
-			return err
-		}
+	projectPath := project.GetStoragePath(project.User.Username)
+	entries, err := storage.Store.List(projectPath)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
 
+	files := []FileInfo{}
+	for _, entry := range entries {
 		fileInfo := FileInfo{
-			Path:      relPath,
-			Name:      d.Name(),
-			IsFolder:  d.IsDir(),
-			Size:      info.Size(),
-			UpdatedAt: info.ModTime().Format(time.RFC3339),
+			Path:      entry.Path,
+			Name:      entry.Name,
+			IsFolder:  entry.IsDir,
+			Size:      entry.Size,
+			UpdatedAt: entry.ModTime.Format(time.RFC3339),
 		}
 
-		if !d.IsDir() {
-			fileInfo.MimeType = utils.GetMimeType(d.Name())
+		if !entry.IsDir {
+			fileInfo.MimeType = utils.GetMimeType(entry.Name)
 		}
 
 		files = append(files, fileInfo)
-		return nil
-	})
-
-	if err != nil {
-		utils.InternalServerError(c, utils.MsgInternalError)
-		return
 	}
 
 	utils.Success(c, files)
 }
 
-// GetFileContentByPath returns file content by path
+// GetFileContentByPath returns file content by path. Text is returned as
+// is; other files are only returned base64 encoded, when ?encoding=base64
+// is given.
 func GetFileContentByPath(c *gin.Context) {
 	projectID := c.Param("id")
 	filePath := c.Query("path")
+	encoding := c.DefaultQuery("encoding", fileEncodingText)
 	userID, _ := c.Get("user_id")
 	isAdmin, _ := c.Get("is_admin")
 
-	if filePath == "" {
+	if filePath == "" || (encoding != fileEncodingText && encoding != fileEncodingBase64) {
 		utils.BadRequest(c, utils.MsgInvalidRequest)
 		return
 	}
@@ -121,9 +105,8 @@ func GetFileContentByPath(c *gin.Context) {
 	}
 
 	// Get project path
-	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	fullPath := filepath.Join(projectPath, filePath)
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, filePath)
 
 	// Security check: ensure the path is within project directory
 	if !isPathSafe(fullPath, projectPath) {
@@ -132,44 +115,149 @@ func GetFileContentByPath(c *gin.Context) {
 	}
 
 	// Check if it's a folder
-	info, err := os.Stat(fullPath)
+	info, err := storage.Store.Stat(fullPath)
 	if err != nil {
 		utils.NotFound(c, utils.MsgFileNotFound)
 		return
 	}
 
-	if info.IsDir() {
+	if info.IsDir {
 		utils.BadRequest(c, utils.MsgInvalidRequest)
 		return
 	}
 
 	// Read file content
-	content, err := utils.ReadFile(fullPath)
+	content, err := storage.Store.Read(fullPath)
 	if err != nil {
 		utils.InternalServerError(c, utils.MsgFileReadFailed)
 		return
 	}
 
+	// Binary content would be mangled in a JSON string
+	if encoding == fileEncodingText && !utils.IsUTF8Text(content) {
+		utils.ErrorWithData(c, 415, utils.MsgFileNotText, map[string]interface{}{
+			"path":      filePath,
+			"name":      path.Base(filePath),
+			"size":      info.Size,
+			"mime_type": utils.GetMimeType(filePath),
+		})
+		return
+	}
+
+	// The hash is sent back when saving to detect concurrent changes
+	hash := services.HashContent(content)
+	c.Header("ETag", `"`+hash+`"`)
+
 	utils.Success(c, map[string]interface{}{
 		"path":       filePath,
-		"name":       filepath.Base(filePath),
-		"size":       info.Size(),
+		"name":       path.Base(filePath),
+		"size":       info.Size,
 		"mime_type":  utils.GetMimeType(filePath),
 		"is_folder":  false,
-		"content":    string(content),
-		"updated_at": info.ModTime().Format(time.RFC3339),
+		"content":    encodeFileContent(content, encoding),
+		"encoding":   encoding,
+		"hash":       hash,
+		"updated_at": info.ModTime.Format(time.RFC3339),
 	})
 }
 
-// UpdateFileContentByPath updates file content by path
+// Encodings of file content in JSON responses
+const (
+	fileEncodingText   = "utf-8"
+	fileEncodingBase64 = "base64"
+)
+
+// encodeFileContent returns file content as a JSON string in the given encoding
+func encodeFileContent(content []byte, encoding string) string {
+	if encoding == fileEncodingBase64 {
+		return base64.StdEncoding.EncodeToString(content)
+	}
+	return string(content)
+}
+
+// DownloadFileByPath streams a file as stored, with range and conditional
+// request support. Files are shown inline unless ?download=1 is given.
+func DownloadFileByPath(c *gin.Context) {
+	projectID := c.Param("id")
+	filePath := c.Query("path")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	if filePath == "" {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	// Get project path
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, filePath)
+
+	// Security check: ensure the path is within project directory
+	if !isPathSafe(fullPath, projectPath) {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
+		return
+	}
+
+	info, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		utils.NotFound(c, utils.MsgFileNotFound)
+		return
+	}
+
+	if info.IsDir {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	f, err := storage.Store.Open(fullPath)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileReadFailed)
+		return
+	}
+	defer f.Close()
+
+	disposition := "inline"
+	if c.Query("download") == "1" {
+		disposition = "attachment"
+	}
+
+	// Hashing large media for every request is too slow, so the entity tag is
+	// derived from the modification time and size
+	c.Header("ETag", fmt.Sprintf(`W/"%x-%x"`, info.ModTime.UnixNano(), info.Size))
+	c.Header("Content-Type", utils.GetMimeType(fullPath))
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": info.Name}))
+	c.Header("Cache-Control", "private, no-cache")
+	// User files must not run scripts on the dashboard origin
+	c.Header("Content-Security-Policy", "sandbox")
+	http.ServeContent(c.Writer, c.Request, info.Name, info.ModTime, f)
+}
+
+// UpdateFileContentByPath updates file content by path. When the hash of
+// the content the client last read is given, as expected_hash or in an
+// If-Match header, the save is rejected with the current content if the
+// file changed since.
 func UpdateFileContentByPath(c *gin.Context) {
 	projectID := c.Param("id")
 	userID, _ := c.Get("user_id")
 	isAdmin, _ := c.Get("is_admin")
 
 	var req struct {
-		Path    string `json:"path" binding:"required"`
-		Content string `json:"content"`
+		Path         string `json:"path" binding:"required"`
+		Content      string `json:"content"`
+		ExpectedHash string `json:"expected_hash"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -177,6 +265,11 @@ func UpdateFileContentByPath(c *gin.Context) {
 		return
 	}
 
+	expectedHash := req.ExpectedHash
+	if expectedHash == "" {
+		expectedHash = parseIfMatch(c.GetHeader("If-Match"))
+	}
+
 	// Get project
 	var project models.Project
 	query := database.DB.Preload("User")
@@ -191,9 +284,8 @@ func UpdateFileContentByPath(c *gin.Context) {
 	}
 
 	// Get project path
-	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	fullPath := filepath.Join(projectPath, req.Path)
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, req.Path)
 
 	// Security check
 	if !isPathSafe(fullPath, projectPath) {
@@ -202,24 +294,116 @@ func UpdateFileContentByPath(c *gin.Context) {
 	}
 
 	// Check if file exists
-	info, err := os.Stat(fullPath)
+	info, err := storage.Store.Stat(fullPath)
 	if err != nil {
 		utils.NotFound(c, utils.MsgFileNotFound)
 		return
 	}
 
-	if info.IsDir() {
+	if info.IsDir {
 		utils.BadRequest(c, utils.MsgInvalidRequest)
 		return
 	}
 
-	// Write file content
-	if err := utils.WriteFile(fullPath, []byte(req.Content)); err != nil {
+	// Check the owner's quota
+	if !checkQuota(c, &project.User, int64(len(req.Content))-info.Size, 0) {
+		return
+	}
+
+	// Check the owner's content policy
+	relPath := strings.TrimPrefix(fullPath, projectPath+"/")
+	warnings, ok := checkContentPolicy(c, &project.User, relPath, int64(len(req.Content)), strings.NewReader(req.Content))
+	if !ok {
+		return
+	}
+
+	// The hash check and the write cannot interleave with another save
+	unlock := lockFileSaves(project.ID)
+	defer unlock()
+
+	previous, err := storage.Store.Read(fullPath)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileReadFailed)
+		return
+	}
+
+	// Reject saves based on stale content
+	currentHash := services.HashContent(previous)
+	if expectedHash != "" && expectedHash != "*" && expectedHash != currentHash {
+		c.Header("ETag", `"`+currentHash+`"`)
+		encoding := fileEncodingText
+		if !utils.IsUTF8Text(previous) {
+			encoding = fileEncodingBase64
+		}
+		utils.ErrorWithData(c, 409, utils.MsgFileConflict, map[string]interface{}{
+			"path":       req.Path,
+			"content":    encodeFileContent(previous, encoding),
+			"encoding":   encoding,
+			"hash":       currentHash,
+			"updated_at": info.ModTime.Format(time.RFC3339),
+		})
+		return
+	}
+
+	if err := saveFileContent(&project, projectPath, relPath, previous, []byte(req.Content), userID.(uint)); err != nil {
 		utils.InternalServerError(c, utils.MsgFileWriteFailed)
 		return
 	}
 
-	utils.SuccessWithCode(c, utils.MsgFileSaved, nil)
+	hash := services.HashContent([]byte(req.Content))
+	c.Header("ETag", `"`+hash+`"`)
+	result := map[string]interface{}{
+		"hash": hash,
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+	utils.SuccessWithCode(c, utils.MsgFileSaved, result)
+}
+
+// fileSaveLocks holds a mutex per project for file saves from the editor
+var fileSaveLocks sync.Map
+
+// lockFileSaves serializes editor saves to a project and returns the unlock
+// function
+func lockFileSaves(projectID uint) func() {
+	lock, _ := fileSaveLocks.LoadOrStore(projectID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+// saveFileContent writes a file saved from the editor and keeps it in the
+// file history, along with the replaced content if the file had no history
+// yet. Callers hold the lock from lockFileSaves.
+func saveFileContent(project *models.Project, projectPath, relPath string, previous, content []byte, userID uint) error {
+	fullPath := path.Join(projectPath, relPath)
+
+	// Keep the content from before the first tracked save as a baseline revision
+	if !services.HasRevisions(project.ID, relPath) {
+		services.RecordRevision(project.ID, relPath, previous, project.UserID)
+	}
+
+	if err := storage.WriteFile(storage.Store, fullPath, content); err != nil {
+		return err
+	}
+	services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeWrite, Path: relPath})
+
+	// Record the saved content in the file history
+	if _, err := services.RecordRevision(project.ID, relPath, content, userID); err != nil {
+		log.Printf("Failed to record revision of %s: %v", fullPath, err)
+	}
+	return nil
+}
+
+// parseIfMatch returns the entity tag of an If-Match header, or "*"
+func parseIfMatch(header string) string {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return header
+	}
+	// Only a single tag is expected; weak tags compare like strong ones here
+	header = strings.TrimPrefix(header, "W/")
+	return strings.Trim(header, `"`)
 }
 
 // RenameFileByPath renames a file by path
@@ -229,8 +413,9 @@ func RenameFileByPath(c *gin.Context) {
 	isAdmin, _ := c.Get("is_admin")
 
 	var req struct {
-		Path    string `json:"path" binding:"required"`
-		NewName string `json:"new_name" binding:"required"`
+		Path             string `json:"path" binding:"required"`
+		NewName          string `json:"new_name" binding:"required"`
+		UpdateReferences bool   `json:"update_references"` // rewrite links to the file in HTML, CSS and JS files
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -261,9 +446,8 @@ func RenameFileByPath(c *gin.Context) {
 	}
 
 	// Get project path
-	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	oldFullPath := filepath.Join(projectPath, req.Path)
+	projectPath := project.GetStoragePath(project.User.Username)
+	oldFullPath := path.Join(projectPath, req.Path)
 
 	// Security check
 	if !isPathSafe(oldFullPath, projectPath) {
@@ -272,27 +456,65 @@ func RenameFileByPath(c *gin.Context) {
 	}
 
 	// Calculate new path
-	dir := filepath.Dir(req.Path)
-	newPath := filepath.Join(dir, newName)
-	newPath = filepath.ToSlash(newPath)
-	newFullPath := filepath.Join(projectPath, newPath)
+	dir := path.Dir(req.Path)
+	newPath := path.Join(dir, newName)
+	newFullPath := path.Join(projectPath, newPath)
 
 	// Check if new path already exists
-	if _, err := os.Stat(newFullPath); err == nil {
+	if _, err := storage.Store.Stat(newFullPath); err == nil {
 		utils.BadRequest(c, utils.MsgInvalidRequest)
 		return
 	}
 
+	// A file getting another extension must pass the owner's content policy
+	if !strings.EqualFold(path.Ext(req.Path), path.Ext(newPath)) {
+		if _, err := services.CheckStoredContentPolicy(&project.User, oldFullPath, newPath); err != nil {
+			writeContentPolicyError(c, err)
+			return
+		}
+	}
+
+	oldPath := strings.TrimPrefix(oldFullPath, projectPath+"/")
+	newPath = strings.TrimPrefix(newFullPath, projectPath+"/")
+
+	// Work out the reference updates while the old paths still exist
+	var updates []services.ReferenceUpdate
+	if req.UpdateReferences {
+		var ok bool
+		if updates, ok = planReferenceUpdates(c, &project, projectPath, oldPath, newPath); !ok {
+			return
+		}
+	}
+
 	// Rename file
-	if err := utils.RenameFile(oldFullPath, newFullPath); err != nil {
+	if err := storage.Store.Rename(oldFullPath, newFullPath); err != nil {
 		utils.InternalServerError(c, utils.MsgFileRenameFailed)
 		return
 	}
 
-	utils.SuccessWithCode(c, utils.MsgFileRenamed, nil)
+	services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeMove, Path: newPath, OldPath: oldPath})
+
+	// Carry the file history over to the new name
+	if err := services.MoveRevisions(project.ID, oldPath, newPath); err != nil {
+		log.Printf("Failed to move revisions of %s: %v", oldFullPath, err)
+	}
+
+	if !req.UpdateReferences {
+		utils.SuccessWithCode(c, utils.MsgFileRenamed, nil)
+		return
+	}
+	if err := services.ApplyReferenceUpdates(&project, projectPath, updates, userID.(uint)); err != nil {
+		log.Printf("Failed to update references to %s: %v", newFullPath, err)
+		utils.InternalServerError(c, utils.MsgFileWriteFailed)
+		return
+	}
+	utils.SuccessWithCode(c, utils.MsgFileRenamed, map[string]interface{}{
+		"path":          newPath,
+		"updated_files": updatedFilePaths(updates),
+	})
 }
 
-// DeleteFileByPath deletes a file by path
+// DeleteFileByPath deletes a file by path, moving it to the project's trash
 func DeleteFileByPath(c *gin.Context) {
 	projectID := c.Param("id")
 	userID, _ := c.Get("user_id")
@@ -327,9 +549,8 @@ func DeleteFileByPath(c *gin.Context) {
 	}
 
 	// Get project path
-	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	fullPath := filepath.Join(projectPath, req.Path)
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, req.Path)
 
 	// Security check
 	if !isPathSafe(fullPath, projectPath) {
@@ -338,25 +559,32 @@ func DeleteFileByPath(c *gin.Context) {
 	}
 
 	// Check if file exists
-	info, err := os.Stat(fullPath)
+	info, err := storage.Store.Stat(fullPath)
 	if err != nil {
 		utils.NotFound(c, utils.MsgFileNotFound)
 		return
 	}
 
-	// Delete file or folder
-	if info.IsDir() {
-		if err := utils.DeleteDir(fullPath); err != nil {
+	// Keep the last content in the file history so it can be restored
+	if err := services.RecordDeletedRevisions(project.ID, projectPath, strings.TrimPrefix(fullPath, projectPath+"/"), userID.(uint)); err != nil {
+		utils.InternalServerError(c, utils.MsgFileDeleteFailed)
+		return
+	}
+
+	// Move file or folder to the project's trash
+	item, err := services.MoveToTrash(project.ID, projectPath, strings.TrimPrefix(fullPath, projectPath+"/"), userID.(uint))
+	if info.IsDir {
+		if err != nil {
 			utils.InternalServerError(c, utils.MsgDirectoryDeleteFailed)
 			return
 		}
-		utils.SuccessWithCode(c, utils.MsgDirectoryDeleted, nil)
+		utils.SuccessWithCode(c, utils.MsgDirectoryDeleted, toTrashItemResponse(item))
 	} else {
-		if err := utils.DeleteFile(fullPath); err != nil {
+		if err != nil {
 			utils.InternalServerError(c, utils.MsgFileDeleteFailed)
 			return
 		}
-		utils.SuccessWithCode(c, utils.MsgFileDeleted, nil)
+		utils.SuccessWithCode(c, utils.MsgFileDeleted, toTrashItemResponse(item))
 	}
 }
 
@@ -367,9 +595,10 @@ func MoveFileByPath(c *gin.Context) {
 	isAdmin, _ := c.Get("is_admin")
 
 	var req struct {
-		SourcePath string `json:"source_path" binding:"required"`
-		TargetPath string `json:"target_path" binding:"required"`
-		Overwrite  bool   `json:"overwrite"`
+		SourcePath       string `json:"source_path" binding:"required"`
+		TargetPath       string `json:"target_path" binding:"required"`
+		Overwrite        bool   `json:"overwrite"`
+		UpdateReferences bool   `json:"update_references"` // rewrite links to the moved files in HTML, CSS and JS files
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -397,11 +626,10 @@ func MoveFileByPath(c *gin.Context) {
 	}
 
 	// Get project path
-	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
+	projectPath := project.GetStoragePath(project.User.Username)
 
-	sourceFullPath := filepath.Join(projectPath, req.SourcePath)
-	targetFullPath := filepath.Join(projectPath, req.TargetPath)
+	sourceFullPath := path.Join(projectPath, req.SourcePath)
+	targetFullPath := path.Join(projectPath, req.TargetPath)
 
 	// Security checks
 	if !isPathSafe(sourceFullPath, projectPath) {
@@ -415,79 +643,331 @@ func MoveFileByPath(c *gin.Context) {
 	}
 
 	// Check if source exists
-	sourceInfo, err := os.Stat(sourceFullPath)
+	sourceInfo, err := storage.Store.Stat(sourceFullPath)
 	if err != nil {
 		utils.NotFound(c, utils.MsgFileNotFound)
 		return
 	}
 
 	// Get source filename
-	sourceFilename := filepath.Base(req.SourcePath)
+	sourceFilename := path.Base(req.SourcePath)
 
 	// Determine final target path
 	var finalTargetPath string
-	targetInfo, err := os.Stat(targetFullPath)
-	if err == nil && targetInfo.IsDir() {
+	targetInfo, err := storage.Store.Stat(targetFullPath)
+	if err == nil && targetInfo.IsDir {
 		// Target is a folder, move into it
-		finalTargetPath = filepath.Join(targetFullPath, sourceFilename)
+		finalTargetPath = path.Join(targetFullPath, sourceFilename)
 	} else {
 		// Target path doesn't exist or is a file, use parent directory
-		targetDir := filepath.Dir(targetFullPath)
-		finalTargetPath = filepath.Join(targetDir, sourceFilename)
+		targetDir := path.Dir(targetFullPath)
+		finalTargetPath = path.Join(targetDir, sourceFilename)
 	}
 
+	// Get relative paths
+	relPath := strings.TrimPrefix(finalTargetPath, projectPath+"/")
+	sourcePath := strings.TrimPrefix(sourceFullPath, projectPath+"/")
+
 	// Check if final target already exists
-	if _, err := os.Stat(finalTargetPath); err == nil {
-		if !req.Overwrite {
-			utils.BadRequest(c, utils.MsgInvalidRequest)
+	_, statErr := storage.Store.Stat(finalTargetPath)
+	if statErr == nil && !req.Overwrite {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Work out the reference updates while the old paths still exist
+	var updates []services.ReferenceUpdate
+	if req.UpdateReferences && relPath != sourcePath {
+		var ok bool
+		if updates, ok = planReferenceUpdates(c, &project, projectPath, sourcePath, relPath); !ok {
 			return
 		}
-		// Overwrite: remove existing file/folder first
-		if err := os.RemoveAll(finalTargetPath); err != nil {
+	}
+
+	// Overwrite: remove existing file/folder first
+	if statErr == nil {
+		if err := storage.Store.Delete(finalTargetPath); err != nil {
 			utils.InternalServerError(c, utils.MsgFileMoveFailed)
 			return
 		}
 	}
 
-	// Ensure parent directory of target exists
-	finalTargetDir := filepath.Dir(finalTargetPath)
-	if err := utils.EnsureDir(finalTargetDir); err != nil {
+	// Move file or folder
+	if err := storage.Store.Rename(sourceFullPath, finalTargetPath); err != nil {
 		utils.InternalServerError(c, utils.MsgFileMoveFailed)
 		return
 	}
 
-	// Move file or folder
-	if err := os.Rename(sourceFullPath, finalTargetPath); err != nil {
-		utils.InternalServerError(c, utils.MsgFileMoveFailed)
+	services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeMove, Path: relPath, OldPath: sourcePath})
+
+	// Carry the file history over to the new location
+	if err := services.MoveRevisions(project.ID, sourcePath, relPath); err != nil {
+		log.Printf("Failed to move revisions of %s: %v", sourceFullPath, err)
+	}
+
+	result := map[string]interface{}{
+		"path":       relPath,
+		"name":       sourceFilename,
+		"size":       sourceInfo.Size,
+		"mime_type":  utils.GetMimeType(sourceFilename),
+		"is_folder":  sourceInfo.IsDir,
+		"updated_at": time.Now().Format(time.RFC3339),
+	}
+	if req.UpdateReferences {
+		if err := services.ApplyReferenceUpdates(&project, projectPath, updates, userID.(uint)); err != nil {
+			log.Printf("Failed to update references to %s: %v", finalTargetPath, err)
+			utils.InternalServerError(c, utils.MsgFileWriteFailed)
+			return
+		}
+		result["updated_files"] = updatedFilePaths(updates)
+	}
+	utils.SuccessWithCode(c, utils.MsgFileMoved, result)
+}
+
+// CopyFileByPath copies a file or folder, optionally into another project
+// owned by the same user. The target is resolved like in MoveFileByPath;
+// copying onto the source itself creates a "name copy" duplicate next to it.
+func CopyFileByPath(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req struct {
+		SourcePath      string `json:"source_path" binding:"required"`
+		TargetPath      string `json:"target_path" binding:"required"`
+		TargetProjectID uint   `json:"target_project_id"` // defaults to the source project
+		NewName         string `json:"new_name"`          // defaults to the source name
+		Overwrite       bool   `json:"overwrite"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get source project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	// Get target project, which must belong to the same user
+	targetProject := project
+	if req.TargetProjectID != 0 && req.TargetProjectID != project.ID {
+		query := database.DB.Preload("User")
+		if !isAdmin.(bool) {
+			query = query.Where("user_id = ?", userID)
+		}
+		if err := query.First(&targetProject, req.TargetProjectID).Error; err != nil {
+			utils.NotFound(c, utils.MsgProjectNotFound)
+			return
+		}
+	}
+
+	// Get project paths
+	projectPath := project.GetStoragePath(project.User.Username)
+	targetProjectPath := targetProject.GetStoragePath(targetProject.User.Username)
+
+	sourceFullPath := path.Join(projectPath, req.SourcePath)
+	targetFullPath := path.Join(targetProjectPath, req.TargetPath)
+
+	// Security checks
+	if !isPathSafe(sourceFullPath, projectPath) || sourceFullPath == projectPath {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
+		return
+	}
+
+	if !isPathSafe(targetFullPath, targetProjectPath) {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
 		return
 	}
 
+	// Check if source exists
+	sourceInfo, err := storage.Store.Stat(sourceFullPath)
+	if err != nil {
+		utils.NotFound(c, utils.MsgFileNotFound)
+		return
+	}
+
+	// Get target filename
+	filename := path.Base(sourceFullPath)
+	if req.NewName != "" {
+		filename = utils.SanitizeFilename(req.NewName)
+		if filename == "" {
+			utils.BadRequest(c, utils.MsgInvalidFileName)
+			return
+		}
+	}
+
+	// Determine final target path
+	var finalTargetPath string
+	targetInfo, err := storage.Store.Stat(targetFullPath)
+	if err == nil && targetInfo.IsDir {
+		// Target is a folder, copy into it
+		finalTargetPath = path.Join(targetFullPath, filename)
+	} else {
+		// Target path doesn't exist or is a file, use parent directory
+		finalTargetPath = path.Join(path.Dir(targetFullPath), filename)
+	}
+
+	// Copying onto the source duplicates it under a free name
+	if finalTargetPath == sourceFullPath {
+		finalTargetPath = duplicateName(sourceFullPath)
+	}
+
+	// A folder cannot be copied into itself, nor onto a folder holding it
+	if strings.HasPrefix(finalTargetPath, sourceFullPath+"/") || strings.HasPrefix(sourceFullPath, finalTargetPath+"/") {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
+		return
+	}
+
+	// Work out how much the copy adds to the target owner's usage
+	addBytes, addFiles := sourceInfo.Size, int64(1)
+	if sourceInfo.IsDir {
+		entries, err := storage.Store.List(sourceFullPath)
+		if err != nil {
+			utils.InternalServerError(c, utils.MsgFileCopyFailed)
+			return
+		}
+		addBytes, addFiles = 0, 0
+		for _, entry := range entries {
+			if !entry.IsDir {
+				addBytes += entry.Size
+				addFiles++
+			}
+		}
+	}
+
+	// Check if final target already exists
+	existing, err := storage.Store.Stat(finalTargetPath)
+	if err == nil {
+		// The root index.html can be replaced by a file, never by a folder
+		if !req.Overwrite || (finalTargetPath == path.Join(targetProjectPath, "index.html") && sourceInfo.IsDir) {
+			utils.BadRequest(c, utils.MsgFileExists)
+			return
+		}
+		if existing.IsDir {
+			entries, err := storage.Store.List(finalTargetPath)
+			if err != nil {
+				utils.InternalServerError(c, utils.MsgFileCopyFailed)
+				return
+			}
+			for _, entry := range entries {
+				if !entry.IsDir {
+					addBytes -= entry.Size
+					addFiles--
+				}
+			}
+		} else {
+			addBytes -= existing.Size
+			addFiles--
+		}
+	}
+
+	// Check the target owner's quota
+	if !checkQuota(c, &targetProject.User, addBytes, addFiles) {
+		return
+	}
+
+	// Check the copies against the target owner's content policy
+	if _, err := services.CheckStoredContentPolicy(&targetProject.User, sourceFullPath, strings.TrimPrefix(finalTargetPath, targetProjectPath+"/")); err != nil {
+		writeContentPolicyError(c, err)
+		return
+	}
+
+	// Copy file or folder. A replaced target is only discarded once the
+	// copy is complete, so a failed copy leaves it in place.
+	tx, err := services.BeginFileTransaction()
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileCopyFailed)
+		return
+	}
+	if err := tx.Copy(sourceFullPath, finalTargetPath); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Failed to roll back copy to %s: %v", finalTargetPath, rbErr)
+		}
+		utils.InternalServerError(c, utils.MsgFileCopyFailed)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to clean up copy backups: %v", err)
+	}
+
 	// Get relative path for response
-	relPath, _ := filepath.Rel(projectPath, finalTargetPath)
-	relPath = filepath.ToSlash(relPath)
+	relPath := strings.TrimPrefix(finalTargetPath, targetProjectPath+"/")
+	services.PublishFileChange(targetProject.ID, services.FileChange{Type: services.FileChangeWrite, Path: relPath})
 
-	utils.SuccessWithCode(c, utils.MsgFileMoved, map[string]interface{}{
+	utils.SuccessWithCode(c, utils.MsgFileCopied, map[string]interface{}{
+		"project_id": targetProject.ID,
 		"path":       relPath,
-		"name":       sourceFilename,
-		"size":       sourceInfo.Size(),
-		"mime_type":  utils.GetMimeType(sourceFilename),
-		"is_folder":  sourceInfo.IsDir(),
+		"name":       path.Base(relPath),
+		"size":       sourceInfo.Size,
+		"mime_type":  utils.GetMimeType(relPath),
+		"is_folder":  sourceInfo.IsDir,
 		"updated_at": time.Now().Format(time.RFC3339),
 	})
 }
 
-// isPathSafe checks if a path is within the project directory
-func isPathSafe(targetPath, projectPath string) bool {
-	// Clean and get absolute paths
-	targetPath = filepath.Clean(targetPath)
-	projectPath = filepath.Clean(projectPath)
+// duplicateName returns a free name next to fullPath for a duplicate,
+// e.g. "page copy.html", "page copy 2.html"
+func duplicateName(fullPath string) string {
+	dir := path.Dir(fullPath)
+	ext := path.Ext(fullPath)
+	base := strings.TrimSuffix(path.Base(fullPath), ext)
+
+	candidate := path.Join(dir, base+" copy"+ext)
+	for i := 2; storage.Exists(storage.Store, candidate); i++ {
+		candidate = path.Join(dir, fmt.Sprintf("%s copy %d%s", base, i, ext))
+	}
+	return candidate
+}
 
-	// Check if target path starts with project path
-	rel, err := filepath.Rel(projectPath, targetPath)
+// planReferenceUpdates works out the reference updates for moving oldPath
+// to newPath and checks them against the owner's quota. It writes the error
+// response and returns false if they cannot be made.
+func planReferenceUpdates(c *gin.Context, project *models.Project, projectPath, oldPath, newPath string) ([]services.ReferenceUpdate, bool) {
+	updates, err := services.PlanReferenceUpdates(&project.User, projectPath, oldPath, newPath)
 	if err != nil {
-		return false
+		if policyViolations(err) != nil {
+			writeContentPolicyError(c, err)
+		} else {
+			utils.InternalServerError(c, utils.MsgFileReadFailed)
+		}
+		return nil, false
+	}
+
+	var addBytes int64
+	for _, update := range updates {
+		addBytes += int64(len(update.Content) - len(update.Previous))
 	}
+	if !checkQuota(c, &project.User, addBytes, 0) {
+		return nil, false
+	}
+	return updates, true
+}
+
+// updatedFilePaths returns the paths of the files changed by reference updates
+func updatedFilePaths(updates []services.ReferenceUpdate) []string {
+	paths := []string{}
+	for _, update := range updates {
+		paths = append(paths, update.Path)
+	}
+	return paths
+}
+
+// isPathSafe checks if a path is within the project directory
+func isPathSafe(targetPath, projectPath string) bool {
+	// Clean both storage paths
+	targetPath = path.Clean(targetPath)
+	projectPath = path.Clean(projectPath)
 
-	// If rel starts with "..", it's outside the project directory
-	return !filepath.IsAbs(rel) && !filepath.HasPrefix(rel, "..")
+	// Target must be the project directory itself or live below it
+	return targetPath == projectPath || strings.HasPrefix(targetPath, projectPath+"/")
 }
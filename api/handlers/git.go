@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+)
+
+// Every project is served as a git repository over the smart HTTP protocol
+// at /git/{name}.git. Pushes to the project's git branch replace its working
+// tree. Git clients rely on HTTP status codes and the WWW-Authenticate
+// header, so these handlers do not use the JSON envelope.
+//
+// Clients authenticate as described for authorizeClientRequest, without
+// account passwords.
+
+// gitServices are the git services that can be run over HTTP
+var gitServices = map[string]bool{
+	"git-upload-pack":  true,
+	"git-receive-pack": true,
+}
+
+// GitInfoRefs advertises the refs of a project repository
+func GitInfoRefs(c *gin.Context) {
+	service := c.Query("service")
+	if !gitServices[service] {
+		c.String(http.StatusForbidden, "only the smart HTTP protocol is supported")
+		return
+	}
+
+	project, _, ok := authorizeGitRequest(c)
+	if !ok {
+		return
+	}
+
+	repoPath, err := services.InitProjectRepo(project)
+	if err != nil {
+		log.Printf("Failed to prepare repository of project %d: %v", project.ID, err)
+		c.String(http.StatusInternalServerError, "failed to open repository")
+		return
+	}
+
+	c.Header("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	c.Writer.WriteString(gitPacketLine("# service=" + service + "\n"))
+	c.Writer.WriteString("0000")
+
+	if err := services.RunGitService(c.Writer, nil, repoPath, strings.TrimPrefix(service, "git-"), true, nil); err != nil {
+		log.Printf("Failed to advertise refs of project %d: %v", project.ID, err)
+	}
+}
+
+// GitUploadPack serves fetches and clones of a project repository
+func GitUploadPack(c *gin.Context) {
+	project, _, ok := authorizeGitRequest(c)
+	if !ok {
+		return
+	}
+
+	body, ok := gitRequestBody(c, "git-upload-pack")
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	repoPath, err := services.InitProjectRepo(project)
+	if err != nil {
+		log.Printf("Failed to prepare repository of project %d: %v", project.ID, err)
+		c.String(http.StatusInternalServerError, "failed to open repository")
+		return
+	}
+
+	c.Header("Content-Type", "application/x-git-upload-pack-result")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	if err := services.RunGitService(c.Writer, body, repoPath, "upload-pack", false, nil); err != nil {
+		log.Printf("Failed to serve fetch of project %d: %v", project.ID, err)
+	}
+}
+
+// GitReceivePack accepts a push to a project repository. When the project's
+// git branch moved, its new commit is synced to the working tree and, if
+// enabled, published.
+func GitReceivePack(c *gin.Context) {
+	project, userID, ok := authorizeGitRequest(c)
+	if !ok {
+		return
+	}
+
+	body, ok := gitRequestBody(c, "git-receive-pack")
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	unlock := services.LockProjectGit(project.ID)
+	defer unlock()
+
+	repoPath, err := services.InitProjectRepo(project)
+	if err != nil {
+		log.Printf("Failed to prepare repository of project %d: %v", project.ID, err)
+		c.String(http.StatusInternalServerError, "failed to open repository")
+		return
+	}
+
+	// Limits enforced by the pre-receive hook
+	env, err := services.ProjectGitEnv(project)
+	if err != nil {
+		log.Printf("Failed to compute push limits of project %d: %v", project.ID, err)
+		c.String(http.StatusInternalServerError, "failed to check quota")
+		return
+	}
+
+	c.Header("Content-Type", "application/x-git-receive-pack-result")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	// The result is held back until the commit is deployed, so a failed
+	// deployment can be reported to the client
+	var result bytes.Buffer
+	defer func() { c.Writer.Write(result.Bytes()) }()
+	if err := services.RunGitService(&result, body, repoPath, "receive-pack", false, env); err != nil {
+		log.Printf("Failed to receive push to project %d: %v", project.ID, err)
+		return
+	}
+
+	commit, err := services.ResolveGitRef(repoPath, services.GetProjectGitRef(project))
+	if err != nil {
+		log.Printf("Failed to resolve git branch of project %d: %v", project.ID, err)
+		return
+	}
+	if commit == "" || commit == project.GitCommit {
+		return
+	}
+
+	if err := services.DeployPushedCommit(project, repoPath, commit, userID); err != nil {
+		log.Printf("Failed to deploy commit %s to project %d: %v", commit, project.ID, err)
+		appendGitProgress(&result, "StaticForge: the push was stored but not deployed: "+err.Error())
+	}
+}
+
+// authorizeGitRequest authorizes a git client for the repository in the URL
+func authorizeGitRequest(c *gin.Context) (*models.Project, uint, bool) {
+	return authorizeClientRequest(c, strings.TrimSuffix(c.Param("repo"), ".git"), false)
+}
+
+// gitRequestBody checks the content type of a git RPC request and returns
+// its body, decompressing gzip encoded requests
+func gitRequestBody(c *gin.Context, service string) (io.ReadCloser, bool) {
+	if c.ContentType() != fmt.Sprintf("application/x-%s-request", service) {
+		c.String(http.StatusUnsupportedMediaType, "unsupported content type")
+		return nil, false
+	}
+
+	if c.GetHeader("Content-Encoding") == "gzip" {
+		body, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid gzip body")
+			return nil, false
+		}
+		return body, true
+	}
+	return c.Request.Body, true
+}
+
+// appendGitProgress adds a message to a side-band receive-pack result, which
+// git shows as "remote:" output. Results without side-band are left as they
+// are, since clients would not accept anything after the report.
+func appendGitProgress(result *bytes.Buffer, message string) {
+	data := result.Bytes()
+	if len(data) < 5 || (data[4] != 1 && data[4] != 2) || !bytes.HasSuffix(data, []byte("0000")) {
+		return
+	}
+	result.Truncate(len(data) - 4)
+	result.WriteString(gitPacketLine("\x02" + message + "\n"))
+	result.WriteString("0000")
+}
+
+// gitPacketLine encodes data as a git pkt-line
+func gitPacketLine(data string) string {
+	return fmt.Sprintf("%04x%s", len(data)+4, data)
+}
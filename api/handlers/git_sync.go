@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// SyncProjectGit pulls the latest commit of a project's git branch from its
+// remote and replaces the working tree with it
+func SyncProjectGit(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	if project.GitRemoteURL == "" {
+		utils.BadRequest(c, utils.MsgGitRemoteNotSet)
+		return
+	}
+
+	syncErr := services.SyncProjectFromRemote(&project, userID.(uint))
+	if syncErr != nil {
+		log.Printf("Failed to sync project %d from git remote: %v", project.ID, syncErr)
+	}
+
+	database.DB.First(&project, project.ID)
+	response := types.GitSyncResponse{
+		Status:   project.GitSyncStatus,
+		Commit:   project.GitCommit,
+		Error:    project.GitSyncError,
+		SyncedAt: formatOptionalTime(project.GitSyncedAt),
+	}
+
+	if syncErr != nil {
+		utils.ErrorWithData(c, 500, utils.MsgGitSyncFailed, response)
+		return
+	}
+	utils.SuccessWithCode(c, utils.MsgGitSynced, response)
+}
+
+// GitDeployHook starts a sync from the git remote of the project owning the
+// hook token. It needs no other authentication, so it can be called by git
+// hosting webhooks; the sync runs in the background and its outcome is
+// reported in the project's git sync status.
+func GitDeployHook(c *gin.Context) {
+	token := c.Param("token")
+
+	var project models.Project
+	if err := database.DB.Preload("User").Where("git_hook_token = ? AND git_remote_url <> ''", token).First(&project).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	if !project.User.IsActive {
+		utils.Forbidden(c, utils.MsgAccountDisabled)
+		return
+	}
+
+	go func() {
+		if err := services.SyncProjectFromRemote(&project, project.UserID); err != nil {
+			log.Printf("Failed to sync project %d from git remote: %v", project.ID, err)
+		}
+	}()
+
+	utils.SuccessWithCode(c, utils.MsgGitSyncStarted, nil)
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// GetFileHistory lists the recorded revisions of a file, newest first
+func GetFileHistory(c *gin.Context) {
+	projectID := c.Param("id")
+	filePath := c.Query("path")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	if filePath == "" {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	// Security check
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, filePath)
+	if !isPathSafe(fullPath, projectPath) {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
+		return
+	}
+
+	var revisions []models.FileRevision
+	if err := database.DB.Where("project_id = ? AND path = ?", project.ID, strings.TrimPrefix(fullPath, projectPath+"/")).
+		Order("id DESC").Find(&revisions).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	revisionResponses := []types.FileRevisionResponse{}
+	for _, revision := range revisions {
+		revisionResponses = append(revisionResponses, toFileRevisionResponse(&revision))
+	}
+
+	utils.Success(c, revisionResponses)
+}
+
+// GetFileHistoryDiff returns a unified diff between two revisions of a file.
+// When "to" is omitted the diff is taken against the current file content.
+func GetFileHistoryDiff(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	fromID := c.Query("from")
+	toID := c.Query("to")
+	if fromID == "" {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var from models.FileRevision
+	if err := database.DB.Where("project_id = ?", project.ID).First(&from, fromID).Error; err != nil {
+		utils.NotFound(c, utils.MsgRevisionNotFound)
+		return
+	}
+
+	fromContent, err := services.GetRevisionContent(&from)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileReadFailed)
+		return
+	}
+
+	var toContent []byte
+	toName := "current"
+	if toID != "" {
+		var to models.FileRevision
+		if err := database.DB.Where("project_id = ? AND path = ?", project.ID, from.Path).First(&to, toID).Error; err != nil {
+			utils.NotFound(c, utils.MsgRevisionNotFound)
+			return
+		}
+		toContent, err = services.GetRevisionContent(&to)
+		if err != nil {
+			utils.InternalServerError(c, utils.MsgFileReadFailed)
+			return
+		}
+		toName = fmt.Sprintf("revision %d", to.ID)
+	} else {
+		// A deleted file diffs against empty content
+		projectPath := project.GetStoragePath(project.User.Username)
+		toContent, _ = storage.Store.Read(path.Join(projectPath, from.Path))
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(fromContent)),
+		B:        difflib.SplitLines(string(toContent)),
+		FromFile: fmt.Sprintf("a/%s (revision %d)", from.Path, from.ID),
+		ToFile:   fmt.Sprintf("b/%s (%s)", from.Path, toName),
+		Context:  3,
+	})
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+
+	utils.Success(c, map[string]interface{}{
+		"path": from.Path,
+		"from": fromID,
+		"to":   toID,
+		"diff": diff,
+	})
+}
+
+// RestoreFileRevision writes the content of a revision back to its file,
+// recreating the file if it has been deleted
+func RestoreFileRevision(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req types.RestoreFileRevisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var revision models.FileRevision
+	if err := database.DB.Where("project_id = ?", project.ID).First(&revision, req.RevisionID).Error; err != nil {
+		utils.NotFound(c, utils.MsgRevisionNotFound)
+		return
+	}
+
+	content, err := services.GetRevisionContent(&revision)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileReadFailed)
+		return
+	}
+
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, revision.Path)
+
+	// The policy may have changed since the revision was saved
+	if _, ok := checkContentPolicy(c, &project.User, revision.Path, int64(len(content)), bytes.NewReader(content)); !ok {
+		return
+	}
+
+	// The restore cannot interleave with a save from the editor
+	unlock := lockFileSaves(project.ID)
+	defer unlock()
+
+	// A folder may have been created where the file used to be
+	addBytes, addFiles := int64(len(content)), int64(1)
+	if info, err := storage.Store.Stat(fullPath); err == nil {
+		if info.IsDir {
+			utils.BadRequest(c, utils.MsgInvalidRequest)
+			return
+		}
+		addBytes, addFiles = int64(len(content))-info.Size, 0
+	}
+
+	// Check the owner's quota
+	if !checkQuota(c, &project.User, addBytes, addFiles) {
+		return
+	}
+
+	if err := storage.WriteFile(storage.Store, fullPath, content); err != nil {
+		utils.InternalServerError(c, utils.MsgFileWriteFailed)
+		return
+	}
+	services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeWrite, Path: revision.Path})
+
+	restored, err := services.RecordRevision(project.ID, revision.Path, content, userID.(uint))
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileWriteFailed)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgFileRestored, toFileRevisionResponse(restored))
+}
+
+// toFileRevisionResponse converts a revision model to its API representation
+func toFileRevisionResponse(revision *models.FileRevision) types.FileRevisionResponse {
+	return types.FileRevisionResponse{
+		ID:        revision.ID,
+		Path:      revision.Path,
+		Hash:      revision.Hash,
+		Size:      revision.Size,
+		UserID:    revision.UserID,
+		CreatedAt: revision.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// ImportProjectArchive extracts an uploaded .zip or .tar.gz archive into a project.
+// In "merge" mode existing files are kept unless the archive contains them;
+// in "replace" mode the target folder is emptied first, moving its files to
+// the trash (the root index.html is only replaced if the archive provides
+// one). If the archive turns out to be broken or a file cannot be written,
+// the project is left unchanged.
+func ImportProjectArchive(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	cfg := config.GetConfig()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.Upload.MaxSize+multipartOverhead)
+
+	// Get archive from form
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	targetDir := c.PostForm("path")
+	if targetDir == "" {
+		targetDir = "/"
+	}
+	stripLeadingDir := c.PostForm("strip_leading_dir") == "true"
+	mode := c.DefaultPostForm("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Validate archive
+	if file.Size > cfg.Upload.MaxSize {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	format := utils.DetectArchiveFormat(file.Filename)
+	if format == "" {
+		utils.BadRequest(c, utils.MsgInvalidArchive)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	projectPath := project.GetStoragePath(project.User.Username)
+	targetPath := path.Join(projectPath, targetDir)
+	if !isPathSafe(targetPath, projectPath) {
+		utils.BadRequest(c, utils.MsgInvalidFilePath)
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgProjectImportFailed)
+		return
+	}
+	defer src.Close()
+
+	// First pass: make sure the archive is readable and find a common leading folder
+	var archiveEntries []utils.ArchiveEntry
+	var names []string
+	if err := utils.WalkArchive(src, file.Size, format, func(entry utils.ArchiveEntry, content io.Reader) error {
+		archiveEntries = append(archiveEntries, entry)
+		names = append(names, entry.Name)
+		return nil
+	}); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidArchive)
+		return
+	}
+
+	leadingDir := ""
+	if stripLeadingDir {
+		leadingDir = commonLeadingDir(names)
+	}
+
+	// Files currently in the target folder; in replace mode all of them
+	// except the root index.html are moved to the trash before extracting.
+	// The folder is created by the import if it does not exist yet.
+	existingEntries, err := storage.Store.List(targetPath)
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		utils.InternalServerError(c, utils.MsgProjectImportFailed)
+		return
+	}
+	rootIndexPath := path.Join(projectPath, "index.html")
+	existingSizes := make(map[string]int64)
+	var addBytes, addFiles int64
+	for _, entry := range existingEntries {
+		if entry.IsDir {
+			continue
+		}
+		// Trashed files still count towards the storage quota
+		if mode == "replace" && path.Join(targetPath, entry.Path) != rootIndexPath {
+			addFiles--
+			continue
+		}
+		existingSizes[entry.Path] = entry.Size
+	}
+
+	// Check the owner's quota against what the archive adds
+	for _, entry := range archiveEntries {
+		if !entry.IsRegular || entry.Size > cfg.Upload.MaxSize {
+			continue
+		}
+		name := path.Clean(importEntryName(entry.Name, leadingDir))
+		if size, ok := existingSizes[name]; ok {
+			addBytes += entry.Size - size
+		} else {
+			addBytes += entry.Size
+			addFiles++
+		}
+	}
+	if !checkQuota(c, &project.User, addBytes, addFiles) {
+		return
+	}
+
+	// The import is applied as a unit, so a broken archive or a failed
+	// write leaves the project as it was
+	tx, err := services.BeginFileTransaction()
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgProjectImportFailed)
+		return
+	}
+	rollback := func() {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Failed to roll back import into project %d: %v", project.ID, rbErr)
+		}
+	}
+
+	// Replace mode: move the contents of the target folder to the trash
+	// first; the trash records are only saved once the import succeeds
+	var trashItems []*models.TrashItem
+	if mode == "replace" {
+		for _, entry := range existingEntries {
+			entryPath := path.Join(targetPath, entry.Path)
+			if strings.Contains(entry.Path, "/") || entryPath == rootIndexPath {
+				continue
+			}
+			relPath := strings.TrimPrefix(entryPath, projectPath+"/")
+			if err := services.RecordDeletedRevisions(project.ID, projectPath, relPath, userID.(uint)); err != nil {
+				rollback()
+				utils.InternalServerError(c, utils.MsgProjectImportFailed)
+				return
+			}
+			item, err := services.NewTrashItem(project.ID, projectPath, relPath, userID.(uint))
+			if err == nil {
+				err = tx.Move(entryPath, item.GetStoragePath())
+			}
+			if err != nil {
+				rollback()
+				utils.InternalServerError(c, utils.MsgProjectImportFailed)
+				return
+			}
+			trashItems = append(trashItems, item)
+		}
+	}
+
+	// Second pass: extract
+	report := types.ImportReport{
+		Imported:    []string{},
+		Overwritten: []string{},
+		Skipped:     []types.ImportSkippedEntry{},
+	}
+	err = utils.WalkArchive(src, file.Size, format, func(entry utils.ArchiveEntry, content io.Reader) error {
+		name := importEntryName(entry.Name, leadingDir)
+		if name == "" || name == "__MACOSX" || strings.HasPrefix(name, "__MACOSX/") {
+			return nil
+		}
+
+		// Zip-slip protection: every entry must land inside the project
+		fullPath := path.Join(targetPath, name)
+		relPath := strings.TrimPrefix(fullPath, projectPath+"/")
+		if !isPathSafe(fullPath, projectPath) || fullPath == projectPath {
+			report.Skipped = append(report.Skipped, types.ImportSkippedEntry{Path: entry.Name, Reason: "unsafe_path"})
+			return nil
+		}
+
+		existing, statErr := storage.Store.Stat(fullPath)
+
+		if entry.IsDir {
+			if statErr == nil {
+				if !existing.IsDir {
+					report.Skipped = append(report.Skipped, types.ImportSkippedEntry{Path: relPath, Reason: "conflict"})
+				}
+				return nil
+			}
+			return tx.Mkdir(fullPath)
+		}
+
+		if !entry.IsRegular {
+			report.Skipped = append(report.Skipped, types.ImportSkippedEntry{Path: relPath, Reason: "unsupported_type"})
+			return nil
+		}
+
+		if entry.Size > cfg.Upload.MaxSize {
+			report.Skipped = append(report.Skipped, types.ImportSkippedEntry{Path: relPath, Reason: "too_large"})
+			return nil
+		}
+
+		if statErr == nil && existing.IsDir {
+			report.Skipped = append(report.Skipped, types.ImportSkippedEntry{Path: relPath, Reason: "conflict"})
+			return nil
+		}
+
+		// The scanned start of the content is kept to be written with the rest
+		var head bytes.Buffer
+		if _, err := services.CheckContentPolicy(&project.User, relPath, entry.Size, io.TeeReader(content, &head)); err != nil {
+			violations := policyViolations(err)
+			if violations == nil {
+				return err
+			}
+			report.Skipped = append(report.Skipped, types.ImportSkippedEntry{Path: relPath, Reason: "content_policy", Violations: violations})
+			return nil
+		}
+
+		if err := tx.WriteFrom(fullPath, io.MultiReader(&head, content), entry.Size); err != nil {
+			return err
+		}
+
+		if statErr == nil {
+			report.Overwritten = append(report.Overwritten, relPath)
+		} else {
+			report.Imported = append(report.Imported, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		rollback()
+		utils.InternalServerError(c, utils.MsgProjectImportFailed)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to clean up import backups: %v", err)
+	}
+	for _, item := range trashItems {
+		if err := services.SaveTrashItem(item); err != nil {
+			log.Printf("Failed to save trash record of %s: %v", item.OriginalPath, err)
+		}
+	}
+	services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeWrite})
+
+	utils.SuccessWithCode(c, utils.MsgProjectImported, report)
+}
+
+// importEntryName returns the project-relative name of an archive entry
+// with the stripped leading folder removed
+func importEntryName(name, leadingDir string) string {
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "/"), leadingDir)
+	return strings.Trim(name, "/")
+}
+
+// commonLeadingDir returns the single top-level folder shared by every
+// archive entry (with a trailing slash), or "" if there is none
+func commonLeadingDir(names []string) string {
+	leading := ""
+	for _, name := range names {
+		name = strings.TrimPrefix(name, "/")
+		idx := strings.Index(name, "/")
+		if idx == -1 {
+			// A file at the archive root means there is nothing to strip
+			return ""
+		}
+		dir := name[:idx+1]
+		if dir == "__MACOSX/" {
+			continue
+		}
+		if leading == "" {
+			leading = dir
+		} else if dir != leading {
+			return ""
+		}
+	}
+	return leading
+}
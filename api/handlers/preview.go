@@ -1,17 +1,77 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
 	"net/http"
-	"path/filepath"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/itsHenry35/StaticForge/config"
 	"github.com/itsHenry35/StaticForge/database"
 	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
 	"github.com/itsHenry35/StaticForge/utils"
 )
 
+// liveReloadKeepAlive is how often an idle change stream is written to, so
+// proxies do not close it
+const liveReloadKeepAlive = 30 * time.Second
+
+// liveReloadCookie remembers that live reload was turned on for the preview
+// of a project, so pages opened from the preview keep it
+const liveReloadCookie = "sf_live_reload"
+
+// liveReloadScript is injected into HTML pages of the preview when live
+// reload is on. It listens to the change stream of the project, swaps
+// stylesheets when only they changed and reloads the page otherwise.
+const liveReloadScript = `<script>
+(function () {
+	var base = %q;
+	var source = new EventSource(%q);
+	var styles = {}, reload = false, timer = null;
+
+	source.addEventListener("change", function (event) {
+		var change = JSON.parse(event.data);
+		if (change.type === "write" && /\.css$/i.test(change.path)) {
+			styles[change.path] = true;
+		} else {
+			reload = true;
+		}
+		clearTimeout(timer);
+		timer = setTimeout(apply, 100);
+	});
+
+	function apply() {
+		var pending = Object.keys(styles).length;
+		if (!reload) {
+			document.querySelectorAll('link[rel~="stylesheet"]').forEach(function (link) {
+				var url = new URL(link.href);
+				if (url.origin !== location.origin || url.pathname.indexOf(base) !== 0) {
+					return;
+				}
+				var name = decodeURIComponent(url.pathname.slice(base.length));
+				if (styles[name]) {
+					url.searchParams.set("sf_reload", Date.now());
+					link.href = url.href;
+					pending--;
+				}
+			});
+		}
+		// Reload for stylesheets that are not linked directly, such as imports
+		if (reload || pending > 0) {
+			location.reload();
+		}
+		styles = {};
+		reload = false;
+	}
+})();
+</script>
+`
+
 // PreviewProject serves project files for authenticated preview without checking is_published
 func PreviewProject(c *gin.Context) {
 	projectID := c.Param("id")
@@ -38,26 +98,108 @@ func PreviewProject(c *gin.Context) {
 
 	// Serve the file
 	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	fullPath := filepath.Join(projectPath, filePath)
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, filePath)
 
-	if !utils.FileExists(fullPath) {
+	info, err := storage.Store.Stat(fullPath)
+	if !isPathSafe(fullPath, projectPath) || err != nil || info.IsDir {
 		c.String(http.StatusNotFound, "File not found")
 		return
 	}
 
-	ext := strings.ToLower(filepath.Ext(filePath))
-	if ext == ".html" || ext == ".css" || ext == ".js" {
-		content, err := utils.ReadFile(fullPath)
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Failed to read file")
-			return
+	if strings.ToLower(path.Ext(fullPath)) == ".html" && liveReloadEnabled(c, project.ID) {
+		serveLiveReloadPage(c, cfg, &project, fullPath)
+		return
+	}
+
+	serveStoredFile(c, cfg, fullPath, info)
+}
+
+// PreviewFileEvents streams the file changes of a project as server-sent
+// "change" events, for reloading the preview
+func PreviewFileEvents(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project, verify ownership
+	var project models.Project
+	query := database.DB
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	changes, stop := services.WatchProjectFiles(project.ID)
+	defer stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	c.Writer.WriteString(": connected\n\n")
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(liveReloadKeepAlive)
+	defer ticker.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case change := <-changes:
+			c.SSEvent("change", change)
+		case <-ticker.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-c.Request.Context().Done():
+			return false
 		}
-		replacedContent := cfg.ApplyReplacements(string(content))
-		c.Header("Content-Type", utils.GetMimeType(filePath))
-		c.String(http.StatusOK, replacedContent)
+		return true
+	})
+}
+
+// liveReloadEnabled reports whether live reload is on for the preview of a
+// project. It is turned on with ?live_reload=1 and off with ?live_reload=0,
+// and remembered in a cookie scoped to the preview.
+func liveReloadEnabled(c *gin.Context, projectID uint) bool {
+	cookiePath := fmt.Sprintf("/api/projects/%d/preview", projectID)
+	secure := c.Request.TLS != nil
+
+	switch c.Query("live_reload") {
+	case "1":
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(liveReloadCookie, "1", 0, cookiePath, "", secure, true)
+		return true
+	case "0":
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(liveReloadCookie, "", -1, cookiePath, "", secure, true)
+		return false
+	}
+
+	value, _ := c.Cookie(liveReloadCookie)
+	return value == "1"
+}
+
+// serveLiveReloadPage serves an HTML page of the preview with the live
+// reload script added before the closing body tag
+func serveLiveReloadPage(c *gin.Context, cfg *config.Config, project *models.Project, fullPath string) {
+	content, err := storage.Store.Read(fullPath)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+
+	html := cfg.ApplyReplacements(string(content))
+	base := fmt.Sprintf("/api/projects/%d/preview/", project.ID)
+	script := fmt.Sprintf(liveReloadScript, base, fmt.Sprintf("/api/projects/%d/preview-events", project.ID))
+	if i := strings.LastIndex(strings.ToLower(html), "</body>"); i >= 0 {
+		html = html[:i] + script + html[i:]
 	} else {
-		c.Header("Content-Type", utils.GetMimeType(filePath))
-		c.File(fullPath)
+		html += script
 	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", utils.GetMimeType(fullPath))
+	c.String(http.StatusOK, html)
 }
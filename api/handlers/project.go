@@ -1,13 +1,17 @@
 package handlers
 
 import (
-	"path/filepath"
+	"bytes"
+	"errors"
+	"path"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/itsHenry35/StaticForge/config"
 	"github.com/itsHenry35/StaticForge/database"
 	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
 	"github.com/itsHenry35/StaticForge/types"
 	"github.com/itsHenry35/StaticForge/utils"
 )
@@ -21,7 +25,9 @@ func GetPublicProjectInfo(c *gin.Context) {
 		return
 	}
 	utils.Success(c, types.PublicProjectInfoResponse{
+		ID:          project.ID,
 		DisplayName: project.User.DisplayName,
+		AllowFork:   project.AllowFork,
 	})
 }
 
@@ -53,6 +59,43 @@ func CreateProject(c *gin.Context) {
 		req.DisplayName = req.Name
 	}
 
+	// Render the files of the chosen template
+	if req.Template == "" {
+		req.Template = services.DefaultTemplate
+	}
+	template, err := services.GetProjectTemplate(req.Template)
+	if err != nil {
+		utils.BadRequest(c, utils.MsgTemplateNotFound)
+		return
+	}
+	templateFiles, err := services.ReadTemplateFiles(template)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgProjectCreationFailed)
+		return
+	}
+	templateFiles = services.RenderTemplateFiles(templateFiles, services.TemplateVars{
+		ProjectName: req.Name,
+		DisplayName: req.DisplayName,
+		Owner:       username.(string),
+	})
+
+	// Check the template files against the owner's quota and content policy
+	var owner models.User
+	if err := database.DB.First(&owner, userID).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+	var templateSize int64
+	for _, file := range templateFiles {
+		templateSize += int64(len(file.Content))
+		if _, ok := checkContentPolicy(c, &owner, file.Path, int64(len(file.Content)), bytes.NewReader(file.Content)); !ok {
+			return
+		}
+	}
+	if !checkQuota(c, &owner, templateSize, int64(len(templateFiles))) {
+		return
+	}
+
 	// Create project record
 	project := models.Project{
 		Name:        req.Name,
@@ -67,35 +110,140 @@ func CreateProject(c *gin.Context) {
 		return
 	}
 
-	// Create project directory
-	projectPath := project.GetPath("data/projects", username.(string))
-	if err := utils.EnsureDir(projectPath); err != nil {
-		utils.InternalServerError(c, utils.MsgProjectCreationFailed)
+	// Write the template files (this also creates the project directory)
+	projectPath := project.GetStoragePath(username.(string))
+	for _, file := range templateFiles {
+		if err := storage.WriteFile(storage.Store, path.Join(projectPath, file.Path), file.Content); err != nil {
+			storage.Store.Delete(projectPath)
+			database.DB.Delete(&project)
+			utils.InternalServerError(c, utils.MsgProjectCreationFailed)
+			return
+		}
+	}
+
+	utils.SuccessWithCode(c, utils.MsgProjectCreated, toProjectResponse(&project, username.(string)))
+}
+
+// ForkProject creates a new project from the files and settings of an
+// existing one. Users fork their own projects from the working tree, and
+// the published site of another user's project if its owner allows forking
+// and the site is not password protected. The password, deployments,
+// history and analytics are not copied.
+func ForkProject(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	username, _ := c.Get("username")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req types.ForkProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
 		return
 	}
 
-	// Create default index.html
-	indexPath := filepath.Join(projectPath, "index.html")
-	defaultHTML := utils.CreateDefaultIndexHTML(req.DisplayName)
-	if err := utils.WriteFile(indexPath, []byte(defaultHTML)); err != nil {
-		utils.InternalServerError(c, utils.MsgProjectCreationFailed)
+	if !utils.ValidateProjectName(req.Name) {
+		utils.BadRequest(c, utils.MsgInvalidProjectName)
 		return
 	}
 
-	utils.SuccessWithCode(c, utils.MsgProjectCreated, types.ProjectResponse{
-		ID:          project.ID,
-		Name:        project.Name,
-		DisplayName: project.DisplayName,
-		Description: project.Description,
-		UserID:      project.UserID,
-		Username:    username.(string),
-		IsPublished: project.IsPublished,
-		IsActive:    project.IsActive,
-		IsSecure:    project.IsSecure,
-		HasPassword: project.HasPassword,
-		CreatedAt:   project.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:   project.UpdatedAt.Format(time.RFC3339),
-	})
+	// Get source project
+	var source models.Project
+	if err := database.DB.Preload("User").First(&source, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	sourcePath := source.GetStoragePath(source.User.Username)
+	if source.UserID != userID.(uint) && !isAdmin.(bool) {
+		// Other users' projects can only be forked from their published site
+		if !source.IsPublished || !source.IsActive {
+			utils.NotFound(c, utils.MsgProjectNotFound)
+			return
+		}
+		// A password protected site would be readable through the fork
+		if !source.AllowFork || source.HasPassword {
+			utils.Forbidden(c, utils.MsgProjectForkNotAllowed)
+			return
+		}
+		var deployment models.Deployment
+		if source.ActiveDeploymentID == nil || database.DB.First(&deployment, *source.ActiveDeploymentID).Error != nil {
+			utils.NotFound(c, utils.MsgProjectNotFound)
+			return
+		}
+		sourcePath = deployment.GetStoragePath()
+	}
+
+	// Check if project name already exists globally
+	var existingProject models.Project
+	if err := database.DB.Where("name = ?", req.Name).First(&existingProject).Error; err == nil {
+		utils.BadRequest(c, utils.MsgProjectExists)
+		return
+	}
+
+	if req.DisplayName == "" {
+		req.DisplayName = source.DisplayName
+	}
+
+	// Check the copied files against the new owner's quota and content policy
+	var owner models.User
+	if err := database.DB.First(&owner, userID).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+	entries, err := storage.Store.List(sourcePath)
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgProjectForkFailed)
+		return
+	}
+	var addBytes, addFiles int64
+	for _, entry := range entries {
+		if !entry.IsDir {
+			addBytes += entry.Size
+			addFiles++
+		}
+	}
+	if !checkQuota(c, &owner, addBytes, addFiles) {
+		return
+	}
+	if _, err := services.CheckStoredContentPolicy(&owner, sourcePath, ""); err != nil {
+		writeContentPolicyError(c, err)
+		return
+	}
+
+	// Create project record
+	project := models.Project{
+		Name:         req.Name,
+		DisplayName:  req.DisplayName,
+		Description:  source.Description,
+		UserID:       userID.(uint),
+		IsPublished:  false,
+		ForkedFromID: &source.ID,
+	}
+
+	if err := database.DB.Create(&project).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgProjectForkFailed)
+		return
+	}
+
+	// is_secure defaults to true, so a false value is only kept by an update
+	if !source.IsSecure {
+		if err := database.DB.Model(&project).Update("is_secure", false).Error; err != nil {
+			database.DB.Delete(&project)
+			utils.InternalServerError(c, utils.MsgProjectForkFailed)
+			return
+		}
+	}
+
+	// Copy the files (this also creates the project directory)
+	projectPath := project.GetStoragePath(username.(string))
+	if err := storage.CopyTree(storage.Store, sourcePath, projectPath); err != nil {
+		storage.Store.Delete(projectPath)
+		database.DB.Delete(&project)
+		utils.InternalServerError(c, utils.MsgProjectForkFailed)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgProjectForked, toProjectResponse(&project, username.(string)))
 }
 
 // GetProjects returns user's projects
@@ -111,20 +259,7 @@ func GetProjects(c *gin.Context) {
 
 	var projectResponses []types.ProjectResponse
 	for _, project := range projects {
-		projectResponses = append(projectResponses, types.ProjectResponse{
-			ID:          project.ID,
-			Name:        project.Name,
-			DisplayName: project.DisplayName,
-			Description: project.Description,
-			UserID:      project.UserID,
-			Username:    username.(string),
-			IsPublished: project.IsPublished,
-			IsActive:    project.IsActive,
-			IsSecure:    project.IsSecure,
-			HasPassword: project.HasPassword,
-			CreatedAt:   project.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   project.UpdatedAt.Format(time.RFC3339),
-		})
+		projectResponses = append(projectResponses, toProjectResponse(&project, username.(string)))
 	}
 
 	utils.Success(c, projectResponses)
@@ -149,22 +284,12 @@ func GetProjectByID(c *gin.Context) {
 		return
 	}
 
+	response := toProjectResponse(&project, project.User.Username)
+	response.OwnerType = project.User.Type
+
 	utils.Success(c, types.ProjectDetailResponse{
-		ProjectResponse: types.ProjectResponse{
-			ID:          project.ID,
-			Name:        project.Name,
-			DisplayName: project.DisplayName,
-			Description: project.Description,
-			UserID:      project.UserID,
-			Username:    project.User.Username,
-			OwnerType:   project.User.Type,
-			IsPublished: project.IsPublished,
-			IsActive:    project.IsActive,
-			IsSecure:    project.IsSecure,
-			HasPassword: project.HasPassword,
-			CreatedAt:   project.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   project.UpdatedAt.Format(time.RFC3339),
-		},
+		ProjectResponse: response,
+		GitHookURL:      gitHookURL(&project),
 	})
 }
 
@@ -206,6 +331,44 @@ func UpdateProject(c *gin.Context) {
 		updates["is_secure"] = *req.IsSecure
 	}
 
+	if req.AllowFork != nil {
+		updates["allow_fork"] = *req.AllowFork
+	}
+
+	if req.GitBranch != nil {
+		if !utils.ValidateGitBranch(*req.GitBranch) {
+			utils.BadRequest(c, utils.MsgInvalidGitBranch)
+			return
+		}
+		updates["git_branch"] = *req.GitBranch
+	}
+
+	if req.GitAutoPublish != nil {
+		updates["git_auto_publish"] = *req.GitAutoPublish
+	}
+
+	// The redacted URL from ProjectResponse leaves the remote unchanged
+	if req.GitRemoteURL != nil && *req.GitRemoteURL != project.GetRedactedGitRemoteURL() {
+		gitConfig := config.GetConfig().Git
+		if *req.GitRemoteURL != "" && !utils.ValidateGitRemoteURL(*req.GitRemoteURL, gitConfig.AllowFileRemotes, gitConfig.AllowPrivateRemotes) {
+			utils.BadRequest(c, utils.MsgInvalidGitRemote)
+			return
+		}
+		updates["git_remote_url"] = *req.GitRemoteURL
+		updates["git_sync_status"] = ""
+		updates["git_sync_error"] = ""
+
+		// Linking a remote enables its deploy hook
+		if *req.GitRemoteURL != "" && project.GitHookToken == "" {
+			token, err := services.GenerateGitHookToken()
+			if err != nil {
+				utils.InternalServerError(c, utils.MsgProjectUpdateFailed)
+				return
+			}
+			updates["git_hook_token"] = token
+		}
+	}
+
 	if len(updates) > 0 {
 		if err := database.DB.Model(&project).Updates(updates).Error; err != nil {
 			utils.InternalServerError(c, utils.MsgProjectUpdateFailed)
@@ -215,20 +378,7 @@ func UpdateProject(c *gin.Context) {
 
 	database.DB.Preload("User").First(&project, projectID)
 
-	utils.SuccessWithCode(c, utils.MsgProjectUpdated, types.ProjectResponse{
-		ID:          project.ID,
-		Name:        project.Name,
-		DisplayName: project.DisplayName,
-		Description: project.Description,
-		UserID:      project.UserID,
-		Username:    project.User.Username,
-		IsPublished: project.IsPublished,
-		IsActive:    project.IsActive,
-		IsSecure:    project.IsSecure,
-		HasPassword: project.HasPassword,
-		CreatedAt:   project.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:   project.UpdatedAt.Format(time.RFC3339),
-	})
+	utils.SuccessWithCode(c, utils.MsgProjectUpdated, toProjectResponse(&project, project.User.Username))
 }
 
 // PublishProject publishes or unpublishes a project
@@ -244,7 +394,7 @@ func PublishProject(c *gin.Context) {
 	}
 
 	var project models.Project
-	query := database.DB
+	query := database.DB.Preload("User")
 
 	if !isAdmin.(bool) {
 		query = query.Where("user_id = ?", userID)
@@ -255,6 +405,17 @@ func PublishProject(c *gin.Context) {
 		return
 	}
 
+	// Publishing freezes the current working tree as a new deployment
+	var deployment *models.Deployment
+	if req.IsPublished {
+		var err error
+		deployment, err = services.CreateDeployment(&project, project.User.Username, userID.(uint))
+		if err != nil {
+			utils.InternalServerError(c, utils.MsgProjectPublishFailed)
+			return
+		}
+	}
+
 	updates := map[string]interface{}{
 		"is_published": req.IsPublished,
 	}
@@ -283,7 +444,7 @@ func PublishProject(c *gin.Context) {
 	}
 
 	if req.IsPublished {
-		utils.SuccessWithCode(c, utils.MsgProjectPublished, nil)
+		utils.SuccessWithCode(c, utils.MsgProjectPublished, toDeploymentResponse(&project, deployment))
 	} else {
 		utils.SuccessWithCode(c, utils.MsgProjectUnpublished, nil)
 	}
@@ -308,9 +469,44 @@ func DeleteProject(c *gin.Context) {
 	}
 
 	// Delete project directory
-	cfg := config.GetConfig()
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	if err := utils.DeleteDir(projectPath); err != nil {
+	projectPath := project.GetStoragePath(project.User.Username)
+	if err := storage.Store.Delete(projectPath); err != nil && !errors.Is(err, storage.ErrNotExist) {
+		utils.InternalServerError(c, utils.MsgProjectDeleteFailed)
+		return
+	}
+
+	// Delete deployments
+	if err := services.DeleteProjectDeployments(project.ID); err != nil {
+		utils.InternalServerError(c, utils.MsgProjectDeleteFailed)
+		return
+	}
+
+	// Delete file history
+	if err := services.DeleteProjectRevisions(project.ID); err != nil {
+		utils.InternalServerError(c, utils.MsgProjectDeleteFailed)
+		return
+	}
+
+	// Delete unfinished uploads
+	if err := services.DeleteProjectUploads(project.ID); err != nil {
+		utils.InternalServerError(c, utils.MsgProjectDeleteFailed)
+		return
+	}
+
+	// Delete trash
+	if err := services.DeleteProjectTrash(project.ID); err != nil {
+		utils.InternalServerError(c, utils.MsgProjectDeleteFailed)
+		return
+	}
+
+	// Delete git repository and deploy tokens
+	if err := services.DeleteProjectGit(project.ID); err != nil {
+		utils.InternalServerError(c, utils.MsgProjectDeleteFailed)
+		return
+	}
+
+	// Delete pending transfers
+	if err := services.DeleteProjectTransfers(project.ID); err != nil {
 		utils.InternalServerError(c, utils.MsgProjectDeleteFailed)
 		return
 	}
@@ -318,6 +514,9 @@ func DeleteProject(c *gin.Context) {
 	// Delete analytics
 	database.DB.Where("project_id = ?", projectID).Delete(&models.Analytics{})
 
+	// Forks keep their files but no longer point at the project
+	database.DB.Model(&models.Project{}).Where("forked_from_id = ?", project.ID).Update("forked_from_id", nil)
+
 	// Delete project
 	if err := database.DB.Delete(&project).Error; err != nil {
 		utils.InternalServerError(c, utils.MsgProjectDeleteFailed)
@@ -337,20 +536,7 @@ func GetAllProjects(c *gin.Context) {
 
 	var projectResponses []types.ProjectResponse
 	for _, project := range projects {
-		projectResponses = append(projectResponses, types.ProjectResponse{
-			ID:          project.ID,
-			Name:        project.Name,
-			DisplayName: project.DisplayName,
-			Description: project.Description,
-			UserID:      project.UserID,
-			Username:    project.User.Username,
-			IsPublished: project.IsPublished,
-			IsActive:    project.IsActive,
-			IsSecure:    project.IsSecure,
-			HasPassword: project.HasPassword,
-			CreatedAt:   project.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   project.UpdatedAt.Format(time.RFC3339),
-		})
+		projectResponses = append(projectResponses, toProjectResponse(&project, project.User.Username))
 	}
 
 	utils.Success(c, projectResponses)
@@ -373,3 +559,47 @@ func ToggleProjectStatus(c *gin.Context) {
 
 	utils.SuccessWithCode(c, utils.MsgProjectUpdated, nil)
 }
+
+// toProjectResponse converts a project to its API representation
+func toProjectResponse(project *models.Project, username string) types.ProjectResponse {
+	return types.ProjectResponse{
+		ID:             project.ID,
+		Name:           project.Name,
+		DisplayName:    project.DisplayName,
+		Description:    project.Description,
+		UserID:         project.UserID,
+		Username:       username,
+		IsPublished:    project.IsPublished,
+		IsActive:       project.IsActive,
+		IsSecure:       project.IsSecure,
+		HasPassword:    project.HasPassword,
+		AllowFork:      project.AllowFork,
+		ForkedFromID:   project.ForkedFromID,
+		CreatedAt:      project.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      project.UpdatedAt.Format(time.RFC3339),
+		GitBranch:      project.GitBranch,
+		GitAutoPublish: project.GitAutoPublish,
+		GitCommit:      project.GitCommit,
+		GitRemoteURL:   project.GetRedactedGitRemoteURL(),
+		GitSyncStatus:  project.GitSyncStatus,
+		GitSyncError:   project.GitSyncError,
+		GitSyncedAt:    formatOptionalTime(project.GitSyncedAt),
+	}
+}
+
+// gitHookURL returns the path of a project's deploy hook, or "" if the
+// project is not linked to a git remote
+func gitHookURL(project *models.Project) string {
+	if project.GitRemoteURL == "" || project.GitHookToken == "" {
+		return ""
+	}
+	return "/api/hooks/git/" + project.GitHookToken
+}
+
+// formatOptionalTime formats a time as RFC 3339, or returns "" if it is not set
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// SetUserQuota sets or clears the per-user quota overrides (admin only)
+func SetUserQuota(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req types.SetUserQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	if (req.MaxBytes != nil && *req.MaxBytes < 0) || (req.MaxFiles != nil && *req.MaxFiles < 0) {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.NotFound(c, utils.MsgUserNotFound)
+		return
+	}
+
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"quota_bytes": req.MaxBytes,
+		"quota_files": req.MaxFiles,
+	}).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgUserUpdateFailed)
+		return
+	}
+
+	user.QuotaBytes = req.MaxBytes
+	user.QuotaFiles = req.MaxFiles
+	utils.SuccessWithCode(c, utils.MsgUserUpdated, getQuotaUsageResponse(&user))
+}
+
+// checkQuota verifies that a write fits in the project owner's quota and
+// writes the error response if it does not
+func checkQuota(c *gin.Context, owner *models.User, addBytes, addFiles int64) bool {
+	if err := services.CheckQuota(owner, addBytes, addFiles); err != nil {
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			utils.Forbidden(c, utils.MsgQuotaExceeded)
+		} else {
+			utils.InternalServerError(c, utils.MsgInternalError)
+		}
+		return false
+	}
+	return true
+}
+
+// getQuotaUsageResponse returns the storage usage and quota of a user,
+// or nil if the usage cannot be computed
+func getQuotaUsageResponse(user *models.User) *types.QuotaUsageResponse {
+	usage, err := services.GetUserUsage(user)
+	if err != nil {
+		return nil
+	}
+
+	limit := services.GetQuotaLimit(user)
+	return &types.QuotaUsageResponse{
+		UsedBytes: usage.Bytes,
+		UsedFiles: usage.Files,
+		MaxBytes:  limit.MaxBytes,
+		MaxFiles:  limit.MaxFiles,
+	}
+}
+
+// checkContentPolicy checks a file about to be written against the content
+// policy of the project owner. It returns the warnings, or writes the error
+// response and returns false if the file is blocked.
+func checkContentPolicy(c *gin.Context, owner *models.User, relPath string, size int64, content io.Reader) ([]types.PolicyViolation, bool) {
+	warnings, err := services.CheckContentPolicy(owner, relPath, size, content)
+	if err != nil {
+		writeContentPolicyError(c, err)
+		return nil, false
+	}
+	return toPolicyViolations(warnings), true
+}
+
+// writeContentPolicyError writes the error response for a failed content
+// policy check, listing the violations
+func writeContentPolicyError(c *gin.Context, err error) {
+	if violations := policyViolations(err); violations != nil {
+		utils.ErrorWithData(c, 422, utils.MsgContentPolicyViolation, map[string]interface{}{
+			"violations": violations,
+		})
+		return
+	}
+	utils.InternalServerError(c, utils.MsgInternalError)
+}
+
+// policyViolations returns the violations of a failed content policy
+// check, or nil for other errors
+func policyViolations(err error) []types.PolicyViolation {
+	var policyErr *services.ContentPolicyError
+	if errors.As(err, &policyErr) {
+		return toPolicyViolations(policyErr.Violations)
+	}
+	return nil
+}
+
+func toPolicyViolations(violations []services.PolicyViolation) []types.PolicyViolation {
+	var result []types.PolicyViolation
+	for _, v := range violations {
+		result = append(result, types.PolicyViolation(v))
+	}
+	return result
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	// maxSearchFileSize is the largest file searched; bigger files are skipped
+	maxSearchFileSize = 2 << 20
+	// maxSearchMatches caps the number of matches returned by a search
+	maxSearchMatches = 1000
+	// maxSearchContext caps the number of context lines around a match
+	maxSearchContext = 10
+)
+
+// SearchProjectFiles searches every text file of a project for a literal or
+// regular expression query
+func SearchProjectFiles(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req types.FileSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	re, err := utils.CompileSearchPattern(req.Query, req.Regex, req.CaseSensitive, req.WholeWord)
+	if err != nil {
+		utils.BadRequest(c, utils.MsgInvalidSearchPattern)
+		return
+	}
+	contextLines := min(max(req.Context, 0), maxSearchContext)
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	response := types.FileSearchResponse{Files: []types.FileSearchResult{}}
+	err = walkSearchFiles(project.GetStoragePath(project.User.Username), &req, func(filePath, content string) bool {
+		matches := utils.SearchContent(content, re, contextLines, maxSearchMatches-response.TotalMatches)
+		if len(matches) == 0 {
+			return true
+		}
+
+		result := types.FileSearchResult{Path: filePath}
+		for _, match := range matches {
+			result.Matches = append(result.Matches, types.FileSearchMatch{
+				Line:   match.Line,
+				Column: match.Column,
+				Length: match.Length,
+				Text:   match.Text,
+				Before: match.Before,
+				After:  match.After,
+			})
+		}
+		response.Files = append(response.Files, result)
+		response.TotalMatches += len(matches)
+
+		if response.TotalMatches >= maxSearchMatches {
+			response.Truncated = true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileReadFailed)
+		return
+	}
+
+	utils.Success(c, response)
+}
+
+// ReplaceInProjectFiles replaces every match of a query in the text files of
+// a project. With dry_run set, only the resulting diffs are returned.
+// Otherwise all files are written together and rolled back if any write fails.
+func ReplaceInProjectFiles(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req types.FileReplaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Patterns matching the empty string would insert the replacement everywhere
+	re, err := utils.CompileSearchPattern(req.Query, req.Regex, req.CaseSensitive, req.WholeWord)
+	if err != nil || re.MatchString("") {
+		utils.BadRequest(c, utils.MsgInvalidSearchPattern)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	// Only the new contents of changed files are kept; the previous ones
+	// are read again when they are needed as a baseline revision
+	type replacedFile struct {
+		path    string
+		content string
+	}
+	var changed []replacedFile
+	var addBytes int64
+	var diffErr error
+	projectPath := project.GetStoragePath(project.User.Username)
+	response := types.FileReplaceResponse{Files: []types.FileReplaceResult{}, DryRun: req.DryRun}
+	err = walkSearchFiles(projectPath, &req.FileSearchRequest, func(filePath, previous string) bool {
+		count := len(re.FindAllStringIndex(previous, -1))
+		if count == 0 {
+			return true
+		}
+
+		content := replaceMatches(re, previous, req.Replacement, req.Regex)
+		if content == previous {
+			return true
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(previous),
+			B:        difflib.SplitLines(content),
+			FromFile: fmt.Sprintf("a/%s", filePath),
+			ToFile:   fmt.Sprintf("b/%s", filePath),
+			Context:  3,
+		})
+		if err != nil {
+			diffErr = err
+			return false
+		}
+
+		response.Files = append(response.Files, types.FileReplaceResult{
+			Path:         filePath,
+			Replacements: count,
+			Diff:         diff,
+		})
+		response.TotalReplacements += count
+		if !req.DryRun {
+			changed = append(changed, replacedFile{path: filePath, content: content})
+		}
+		addBytes += int64(len(content) - len(previous))
+		return true
+	})
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgFileReadFailed)
+		return
+	}
+	if diffErr != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+
+	if req.DryRun || len(changed) == 0 {
+		utils.Success(c, response)
+		return
+	}
+
+	// Check the owner's quota
+	if !checkQuota(c, &project.User, addBytes, 0) {
+		return
+	}
+
+	// Check the new contents against the owner's content policy
+	for _, file := range changed {
+		if _, ok := checkContentPolicy(c, &project.User, file.path, int64(len(file.content)), strings.NewReader(file.content)); !ok {
+			return
+		}
+	}
+
+	tx, err := services.BeginFileTransaction()
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+	for _, file := range changed {
+		fullPath := path.Join(projectPath, file.path)
+
+		// Keep the content from before the first tracked save as a baseline revision
+		if !services.HasRevisions(project.ID, file.path) {
+			if previous, err := storage.Store.Read(fullPath); err == nil {
+				services.RecordRevision(project.ID, file.path, previous, project.UserID)
+			}
+		}
+
+		if err := tx.Write(fullPath, []byte(file.content)); err != nil {
+			log.Printf("Failed to write %s during replace: %v", file.path, err)
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("Failed to roll back replace: %v", rbErr)
+			}
+			utils.InternalServerError(c, utils.MsgFileWriteFailed)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to clean up replace backups: %v", err)
+	}
+	for _, file := range changed {
+		services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeWrite, Path: file.path})
+	}
+
+	// Record the new contents in the file history
+	for _, file := range changed {
+		if _, err := services.RecordRevision(project.ID, file.path, []byte(file.content), userID.(uint)); err != nil {
+			log.Printf("Failed to record revision of %s: %v", file.path, err)
+		}
+	}
+
+	utils.SuccessWithCode(c, utils.MsgFilesReplaced, response)
+}
+
+// walkSearchFiles calls fn with the text files of a project matching the
+// include and exclude globs of a search, reading one file at a time, until
+// fn returns false. Binary and oversized files are skipped.
+func walkSearchFiles(projectPath string, req *types.FileSearchRequest, fn func(filePath, content string) bool) error {
+	entries, err := storage.Store.List(projectPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir || entry.Size > maxSearchFileSize {
+			continue
+		}
+		if req.Include != "" && !utils.MatchGlobs(req.Include, entry.Path) {
+			continue
+		}
+		if req.Exclude != "" && utils.MatchGlobs(req.Exclude, entry.Path) {
+			continue
+		}
+
+		content, err := storage.Store.Read(path.Join(projectPath, entry.Path))
+		if err != nil {
+			return err
+		}
+		if !utils.IsTextFile(entry.Name, content) {
+			continue
+		}
+		if !fn(entry.Path, string(content)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// replaceMatches replaces every match of re in content. Regex replacements
+// may reference capture groups; literal replacements are inserted as is.
+func replaceMatches(re *regexp.Regexp, content, replacement string, isRegex bool) string {
+	if isRegex {
+		return re.ReplaceAllString(content, replacement)
+	}
+	return re.ReplaceAllLiteralString(content, replacement)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// GetSSHKeys lists the SSH keys of the current user
+func GetSSHKeys(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var keys []models.SSHKey
+	if err := database.DB.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	keyResponses := []types.SSHKeyResponse{}
+	for _, key := range keys {
+		keyResponses = append(keyResponses, toSSHKeyResponse(&key))
+	}
+
+	utils.Success(c, keyResponses)
+}
+
+// AddSSHKey adds an SSH public key the current user can log in to the SFTP
+// server with
+func AddSSHKey(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req types.AddSSHKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	publicKey, fingerprint, err := services.ParseSSHPublicKey(req.PublicKey)
+	if err != nil {
+		utils.BadRequest(c, utils.MsgInvalidSSHKey)
+		return
+	}
+
+	// A key identifies its user, so it can only be added once
+	var count int64
+	database.DB.Model(&models.SSHKey{}).Where("fingerprint = ?", fingerprint).Count(&count)
+	if count > 0 {
+		utils.BadRequest(c, utils.MsgSSHKeyExists)
+		return
+	}
+
+	key := models.SSHKey{
+		UserID:      userID.(uint),
+		Name:        req.Name,
+		PublicKey:   publicKey,
+		Fingerprint: fingerprint,
+	}
+	if err := database.DB.Create(&key).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgSSHKeyAdded, toSSHKeyResponse(&key))
+}
+
+// DeleteSSHKey removes an SSH key of the current user
+func DeleteSSHKey(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	keyID := c.Param("key_id")
+
+	var key models.SSHKey
+	if err := database.DB.Where("user_id = ?", userID).First(&key, keyID).Error; err != nil {
+		utils.NotFound(c, utils.MsgSSHKeyNotFound)
+		return
+	}
+
+	if err := database.DB.Delete(&key).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgSSHKeyDeleted, nil)
+}
+
+// toSSHKeyResponse converts an SSH key to its API representation
+func toSSHKeyResponse(key *models.SSHKey) types.SSHKeyResponse {
+	return types.SSHKeyResponse{
+		ID:          key.ID,
+		Name:        key.Name,
+		Fingerprint: key.Fingerprint,
+		CreatedAt:   key.CreatedAt.Format(time.RFC3339),
+		LastUsedAt:  formatOptionalTime(key.LastUsedAt),
+	}
+}
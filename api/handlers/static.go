@@ -4,7 +4,7 @@ import (
 	"crypto/md5"
 	"fmt"
 	"net/http"
-	"path/filepath"
+	"path"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +12,7 @@ import (
 	"github.com/itsHenry35/StaticForge/database"
 	"github.com/itsHenry35/StaticForge/models"
 	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
 	"github.com/itsHenry35/StaticForge/utils"
 )
 
@@ -144,11 +145,19 @@ func ServeStaticSite(c *gin.Context) {
 		services.RecordVisit(project.ID, visitorID)
 	}
 
-	// Serve file
-	projectPath := project.GetPath(cfg.Upload.DataDir, project.User.Username)
-	fullPath := filepath.Join(projectPath, filePath)
+	// Serve file from the active deployment. Projects published before
+	// deployments existed keep serving their working tree until republished.
+	sitePath := project.GetStoragePath(project.User.Username)
+	if project.ActiveDeploymentID != nil {
+		var deployment models.Deployment
+		if err := database.DB.First(&deployment, *project.ActiveDeploymentID).Error; err == nil {
+			sitePath = deployment.GetStoragePath()
+		}
+	}
+	fullPath := path.Join(sitePath, filePath)
 
-	if !utils.FileExists(fullPath) {
+	info, err := storage.Store.Stat(fullPath)
+	if !isPathSafe(fullPath, sitePath) || err != nil || info.IsDir {
 		ServeErrorPage(c, http.StatusNotFound, "filenotfound.html", map[string]string{
 			"project": projectName,
 			"file":    filePath,
@@ -156,17 +165,37 @@ func ServeStaticSite(c *gin.Context) {
 		return
 	}
 
-	ext := strings.ToLower(filepath.Ext(filePath))
-	if ext == ".html" || ext == ".css" || ext == ".js" {
-		content, err := utils.ReadFile(fullPath)
+	serveStoredFile(c, cfg, fullPath, info)
+}
+
+// serveStoredFile writes a project file from storage to the response. HTML, CSS
+// and JS files have the configured replacement rules applied; everything else
+// is streamed with range request support.
+func serveStoredFile(c *gin.Context, cfg *config.Config, fullPath string, info *storage.FileInfo) {
+	if hasReplacements(fullPath) {
+		content, err := storage.Store.Read(fullPath)
 		if err != nil {
 			c.String(http.StatusInternalServerError, "Failed to read file")
 			return
 		}
-		c.Header("Content-Type", utils.GetMimeType(filePath))
+		c.Header("Content-Type", utils.GetMimeType(fullPath))
 		c.String(http.StatusOK, cfg.ApplyReplacements(string(content)))
-	} else {
-		c.Header("Content-Type", utils.GetMimeType(filePath))
-		c.File(fullPath)
+		return
 	}
+
+	f, err := storage.Store.Open(fullPath)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	defer f.Close()
+
+	c.Header("Content-Type", utils.GetMimeType(fullPath))
+	http.ServeContent(c.Writer, c.Request, info.Name, info.ModTime, f)
+}
+
+// hasReplacements reports whether the configured replacement rules apply to a file
+func hasReplacements(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".html" || ext == ".css" || ext == ".js"
 }
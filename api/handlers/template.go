@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// maxTemplatePreviewSize is the largest preview image accepted for a template
+const maxTemplatePreviewSize = 2 << 20
+
+// templatePreviewExtensions are the image types accepted as template previews
+var templatePreviewExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg"}
+
+// GetProjectTemplates lists the templates new projects can start from
+func GetProjectTemplates(c *gin.Context) {
+	templates, err := services.ListProjectTemplates()
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	templateResponses := []types.ProjectTemplateResponse{}
+	for _, template := range templates {
+		templateResponses = append(templateResponses, toProjectTemplateResponse(&template))
+	}
+
+	utils.Success(c, templateResponses)
+}
+
+// GetProjectTemplatePreview serves the preview image of a template
+func GetProjectTemplatePreview(c *gin.Context) {
+	template, err := services.GetProjectTemplate(c.Param("name"))
+	if err != nil {
+		utils.NotFound(c, utils.MsgTemplateNotFound)
+		return
+	}
+
+	content, name, err := services.ReadTemplatePreview(template)
+	if err != nil {
+		utils.NotFound(c, utils.MsgNotFound)
+		return
+	}
+
+	// Previews may be SVG uploaded by an admin; never run their scripts
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, utils.GetMimeType(name), content)
+}
+
+// UploadProjectTemplate adds a template from a .zip or .tar.gz archive of
+// its files. A common leading folder is stripped, and the files must include
+// a root index.html. An optional "preview" image is shown when choosing the
+// template.
+func UploadProjectTemplate(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	cfg := config.GetConfig()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.Upload.MaxSize+maxTemplatePreviewSize+multipartOverhead)
+
+	name := c.PostForm("name")
+	if !utils.ValidateTemplateName(name) {
+		utils.BadRequest(c, utils.MsgInvalidTemplateName)
+		return
+	}
+	displayName := c.PostForm("display_name")
+	if displayName == "" {
+		displayName = name
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil || file.Size > cfg.Upload.MaxSize {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+	format := utils.DetectArchiveFormat(file.Filename)
+	if format == "" {
+		utils.BadRequest(c, utils.MsgInvalidArchive)
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+	defer src.Close()
+
+	files, ok := readTemplateArchive(c, src, file.Size, format, cfg.Upload.MaxSize)
+	if !ok {
+		return
+	}
+
+	template := models.ProjectTemplate{
+		Name:        name,
+		DisplayName: displayName,
+		Description: c.PostForm("description"),
+		CreatedBy:   userID.(uint),
+	}
+
+	// Read the preview image
+	var preview []byte
+	if previewFile, err := c.FormFile("preview"); err == nil {
+		ext := strings.ToLower(path.Ext(previewFile.Filename))
+		if previewFile.Size > maxTemplatePreviewSize || !utils.HasFileExtension(previewFile.Filename, templatePreviewExtensions) {
+			utils.BadRequest(c, utils.MsgInvalidRequest)
+			return
+		}
+		src, err := previewFile.Open()
+		if err != nil {
+			utils.InternalServerError(c, utils.MsgInternalError)
+			return
+		}
+		preview, err = io.ReadAll(src)
+		src.Close()
+		if err != nil {
+			utils.InternalServerError(c, utils.MsgInternalError)
+			return
+		}
+		template.PreviewName = "preview" + ext
+	}
+
+	if err := services.CreateProjectTemplate(&template, files, preview); err != nil {
+		if errors.Is(err, services.ErrTemplateExists) {
+			utils.BadRequest(c, utils.MsgTemplateExists)
+			return
+		}
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgTemplateCreated, toProjectTemplateResponse(&template))
+}
+
+// DeleteProjectTemplate removes an uploaded template. Projects created from
+// it keep their files.
+func DeleteProjectTemplate(c *gin.Context) {
+	template, err := services.GetProjectTemplate(c.Param("name"))
+	if err != nil {
+		utils.NotFound(c, utils.MsgTemplateNotFound)
+		return
+	}
+
+	// Built-in templates cannot be removed
+	if template.IsBuiltIn() {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	if err := services.DeleteProjectTemplate(template); err != nil {
+		utils.InternalServerError(c, utils.MsgInternalError)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgTemplateDeleted, nil)
+}
+
+// readTemplateArchive reads the files of a template archive into memory,
+// limited to maxSize bytes in total. It writes the error response and
+// returns false if the archive is not a valid template.
+func readTemplateArchive(c *gin.Context, src utils.ArchiveReader, size int64, format string, maxSize int64) ([]services.TemplateFile, bool) {
+	var files []services.TemplateFile
+	var names []string
+	var total int64
+	err := utils.WalkArchive(src, size, format, func(entry utils.ArchiveEntry, content io.Reader) error {
+		names = append(names, entry.Name)
+		if !entry.IsRegular {
+			return nil
+		}
+		total += entry.Size
+		if total > maxSize {
+			return errors.New("template exceeds maximum size")
+		}
+		data, err := io.ReadAll(io.LimitReader(content, entry.Size))
+		if err != nil {
+			return err
+		}
+		files = append(files, services.TemplateFile{Path: entry.Name, Content: data})
+		return nil
+	})
+	if err != nil {
+		utils.BadRequest(c, utils.MsgInvalidArchive)
+		return nil, false
+	}
+
+	// Keep the files below a common leading folder, skipping unsafe paths
+	leadingDir := commonLeadingDir(names)
+	var result []services.TemplateFile
+	hasIndex := false
+	for _, file := range files {
+		name := importEntryName(file.Path, leadingDir)
+		if name == "" || name == "__MACOSX" || strings.HasPrefix(name, "__MACOSX/") {
+			continue
+		}
+		name = path.Clean(name)
+		if name == ".." || strings.HasPrefix(name, "../") {
+			continue
+		}
+		hasIndex = hasIndex || name == "index.html"
+		result = append(result, services.TemplateFile{Path: name, Content: file.Content})
+	}
+	if !hasIndex {
+		utils.BadRequest(c, utils.MsgInvalidTemplate)
+		return nil, false
+	}
+	return result, true
+}
+
+// toProjectTemplateResponse converts a template to its API representation
+func toProjectTemplateResponse(template *models.ProjectTemplate) types.ProjectTemplateResponse {
+	response := types.ProjectTemplateResponse{
+		Name:        template.Name,
+		DisplayName: template.DisplayName,
+		Description: template.Description,
+		BuiltIn:     template.IsBuiltIn(),
+		FileCount:   template.FileCount,
+		Size:        template.Size,
+	}
+	if template.PreviewName != "" {
+		response.PreviewURL = fmt.Sprintf("/api/templates/%s/preview", template.Name)
+	}
+	if !template.IsBuiltIn() {
+		response.CreatedAt = template.CreatedAt.Format(time.RFC3339)
+	}
+	return response
+}
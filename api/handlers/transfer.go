@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// RequestProjectTransfer offers a project to another user. The owner
+// changes once the recipient accepts; a new request replaces a pending one.
+func RequestProjectTransfer(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req types.TransferProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, utils.MsgInvalidRequest)
+		return
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var recipient models.User
+	if err := database.DB.Where("username = ?", req.Username).First(&recipient).Error; err != nil {
+		utils.NotFound(c, utils.MsgUserNotFound)
+		return
+	}
+	if recipient.ID == project.UserID || !recipient.IsActive {
+		utils.BadRequest(c, utils.MsgInvalidTransferTarget)
+		return
+	}
+
+	if err := services.DeleteProjectTransfers(project.ID); err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	transfer := models.ProjectTransfer{
+		ProjectID:  project.ID,
+		FromUserID: project.UserID,
+		ToUserID:   recipient.ID,
+		CreatedBy:  userID.(uint),
+		Project:    project,
+		FromUser:   project.User,
+		ToUser:     recipient,
+	}
+	if err := database.DB.Omit("Project", "FromUser", "ToUser").Create(&transfer).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgTransferRequested, toProjectTransferResponse(&transfer))
+}
+
+// GetProjectTransfer returns the pending transfer of a project
+func GetProjectTransfer(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var transfer models.ProjectTransfer
+	if err := database.DB.Preload("Project").Preload("FromUser").Preload("ToUser").
+		Where("project_id = ?", project.ID).First(&transfer).Error; err != nil {
+		utils.NotFound(c, utils.MsgTransferNotFound)
+		return
+	}
+
+	utils.Success(c, toProjectTransferResponse(&transfer))
+}
+
+// CancelProjectTransfer withdraws the pending transfer of a project
+func CancelProjectTransfer(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	result := database.DB.Where("project_id = ?", project.ID).Delete(&models.ProjectTransfer{})
+	if result.Error != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFound(c, utils.MsgTransferNotFound)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgTransferCanceled, nil)
+}
+
+// GetIncomingTransfers lists the projects offered to the current user
+func GetIncomingTransfers(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var transfers []models.ProjectTransfer
+	if err := database.DB.Preload("Project").Preload("FromUser").Preload("ToUser").
+		Where("to_user_id = ?", userID).Order("created_at DESC").Find(&transfers).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	transferResponses := []types.ProjectTransferResponse{}
+	for _, transfer := range transfers {
+		transferResponses = append(transferResponses, toProjectTransferResponse(&transfer))
+	}
+
+	utils.Success(c, transferResponses)
+}
+
+// AcceptProjectTransfer makes the current user the owner of a project
+// offered to them. The project folder moves to their storage; the public
+// URL, deployments, history and analytics stay as they are.
+func AcceptProjectTransfer(c *gin.Context) {
+	transferID := c.Param("transfer_id")
+	userID, _ := c.Get("user_id")
+
+	var transfer models.ProjectTransfer
+	if err := database.DB.Preload("ToUser").Where("to_user_id = ?", userID).First(&transfer, transferID).Error; err != nil {
+		utils.NotFound(c, utils.MsgTransferNotFound)
+		return
+	}
+
+	// Transfers requested before the project changed hands are void
+	var project models.Project
+	if err := database.DB.Preload("User").First(&project, transfer.ProjectID).Error; err != nil || project.UserID != transfer.FromUserID {
+		database.DB.Delete(&transfer)
+		utils.NotFound(c, utils.MsgTransferNotFound)
+		return
+	}
+
+	// Save open collaborative edits, then hold editor saves and git syncs
+	// while the project moves
+	disconnectCollabEditors(project.ID)
+	unlock := lockFileSaves(project.ID)
+	defer unlock()
+	unlockGit := services.LockProjectGit(project.ID)
+	defer unlockGit()
+
+	if err := services.TransferProject(&project, &transfer.ToUser); err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuotaExceeded):
+			utils.Forbidden(c, utils.MsgQuotaExceeded)
+		case policyViolations(err) != nil:
+			writeContentPolicyError(c, err)
+		case errors.Is(err, services.ErrTransferTargetExists):
+			utils.BadRequest(c, utils.MsgFileExists)
+		default:
+			utils.InternalServerError(c, utils.MsgTransferFailed)
+		}
+		return
+	}
+	services.PublishFileChange(project.ID, services.FileChange{Type: services.FileChangeWrite})
+
+	utils.SuccessWithCode(c, utils.MsgTransferAccepted, nil)
+}
+
+// DeclineProjectTransfer turns down a project offered to the current user
+func DeclineProjectTransfer(c *gin.Context) {
+	transferID := c.Param("transfer_id")
+	userID, _ := c.Get("user_id")
+
+	var transfer models.ProjectTransfer
+	if err := database.DB.Where("to_user_id = ?", userID).First(&transfer, transferID).Error; err != nil {
+		utils.NotFound(c, utils.MsgTransferNotFound)
+		return
+	}
+
+	if err := database.DB.Delete(&transfer).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgTransferDeclined, nil)
+}
+
+// toProjectTransferResponse converts a transfer with its project and users
+// loaded to its API representation
+func toProjectTransferResponse(transfer *models.ProjectTransfer) types.ProjectTransferResponse {
+	return types.ProjectTransferResponse{
+		ID:                 transfer.ID,
+		ProjectID:          transfer.ProjectID,
+		ProjectName:        transfer.Project.Name,
+		ProjectDisplayName: transfer.Project.DisplayName,
+		FromUsername:       transfer.FromUser.Username,
+		ToUsername:         transfer.ToUser.Username,
+		CreatedAt:          transfer.CreatedAt.Format(time.RFC3339),
+	}
+}
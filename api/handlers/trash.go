@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/types"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// GetProjectTrash lists the deleted files and folders of a project, newest first
+func GetProjectTrash(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var items []models.TrashItem
+	if err := database.DB.Preload("DeletedByUser").Where("project_id = ?", project.ID).Order("id DESC").Find(&items).Error; err != nil {
+		utils.InternalServerError(c, utils.MsgDatabaseError)
+		return
+	}
+
+	itemResponses := []types.TrashItemResponse{}
+	for _, item := range items {
+		itemResponses = append(itemResponses, toTrashItemResponse(&item))
+	}
+
+	utils.Success(c, itemResponses)
+}
+
+// RestoreTrashItem moves a trash item back to its original path
+func RestoreTrashItem(c *gin.Context) {
+	projectID := c.Param("id")
+	trashID := c.Param("trash_id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var req types.RestoreTrashItemRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, utils.MsgInvalidRequest)
+			return
+		}
+	}
+
+	// Get project
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var item models.TrashItem
+	if err := database.DB.Where("project_id = ?", project.ID).First(&item, trashID).Error; err != nil {
+		utils.NotFound(c, utils.MsgTrashItemNotFound)
+		return
+	}
+
+	// The trash already counts towards the owner's storage quota, but
+	// restored files count towards the file quota again
+	if !checkQuota(c, &project.User, 0, item.FileCount) {
+		return
+	}
+
+	// The owner's content policy may have changed since the item was deleted
+	if _, err := services.CheckStoredContentPolicy(&project.User, item.GetStoragePath(), item.OriginalPath); err != nil {
+		writeContentPolicyError(c, err)
+		return
+	}
+
+	projectPath := project.GetStoragePath(project.User.Username)
+	if err := services.RestoreTrashItem(&item, projectPath, req.Overwrite, userID.(uint)); err != nil {
+		if errors.Is(err, services.ErrTrashRestoreConflict) {
+			utils.BadRequest(c, utils.MsgFileExists)
+		} else {
+			utils.InternalServerError(c, utils.MsgTrashRestoreFailed)
+		}
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgTrashItemRestored, map[string]interface{}{
+		"path":      item.OriginalPath,
+		"is_folder": item.IsDir,
+	})
+}
+
+// PurgeTrashItem permanently deletes a trash item
+func PurgeTrashItem(c *gin.Context) {
+	projectID := c.Param("id")
+	trashID := c.Param("trash_id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	var item models.TrashItem
+	if err := database.DB.Where("project_id = ?", project.ID).First(&item, trashID).Error; err != nil {
+		utils.NotFound(c, utils.MsgTrashItemNotFound)
+		return
+	}
+
+	if err := services.PurgeTrashItem(&item); err != nil {
+		utils.InternalServerError(c, utils.MsgTrashPurgeFailed)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgTrashItemPurged, nil)
+}
+
+// EmptyProjectTrash permanently deletes every trash item of a project
+func EmptyProjectTrash(c *gin.Context) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	// Get project
+	var project models.Project
+	query := database.DB
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, utils.MsgProjectNotFound)
+		return
+	}
+
+	if err := services.DeleteProjectTrash(project.ID); err != nil {
+		utils.InternalServerError(c, utils.MsgTrashPurgeFailed)
+		return
+	}
+
+	utils.SuccessWithCode(c, utils.MsgTrashEmptied, nil)
+}
+
+// toTrashItemResponse converts a trash item to its API representation
+func toTrashItemResponse(item *models.TrashItem) types.TrashItemResponse {
+	response := types.TrashItemResponse{
+		ID:                item.ID,
+		Path:              item.OriginalPath,
+		IsFolder:          item.IsDir,
+		Size:              item.Size,
+		FileCount:         item.FileCount,
+		DeletedBy:         item.DeletedBy,
+		DeletedByUsername: item.DeletedByUser.Username,
+		DeletedAt:         item.CreatedAt.Format(time.RFC3339),
+	}
+	if days := config.GetConfig().Trash.RetentionDays; days > 0 {
+		response.ExpiresAt = item.CreatedAt.AddDate(0, 0, days).Format(time.RFC3339)
+	}
+	return response
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// Resumable uploads implement the tus 1.0 protocol (https://tus.io) with the
+// creation, termination and expiration extensions. tus clients rely on HTTP
+// status codes and headers, so these handlers do not use the JSON envelope.
+//
+// Upload-Metadata keys:
+//   - filename:  name of the file (required)
+//   - path:      target folder inside the project, defaults to "/"
+//   - overwrite: "true" to replace an existing file
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+)
+
+// TusOptions describes the server's tus capabilities
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(config.GetConfig().Upload.MaxSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateUpload starts a resumable upload. Size and quota are checked up
+// front from Upload-Length, before any data is sent; unfinished uploads of
+// the owner count towards the quota with their full length.
+func CreateUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	project, ok := getUploadProject(c)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	if length > config.GetConfig().Upload.MaxSize {
+		c.String(http.StatusRequestEntityTooLarge, "upload exceeds maximum size")
+		return
+	}
+
+	metadata := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	filename := utils.SanitizeFilename(metadata["filename"])
+	if filename == "" {
+		c.String(http.StatusBadRequest, "missing filename metadata")
+		return
+	}
+	dir := metadata["path"]
+	if dir == "" {
+		dir = "/"
+	}
+	overwrite := metadata["overwrite"] == "true"
+
+	projectPath := project.GetStoragePath(project.User.Username)
+	relativePath := strings.TrimPrefix(path.Join("/", dir, filename), "/")
+	fullPath := path.Join(projectPath, relativePath)
+
+	// Security check
+	if !isPathSafe(fullPath, projectPath) {
+		c.String(http.StatusBadRequest, "invalid file path")
+		return
+	}
+
+	status, addBytes, addFiles := checkUploadTarget(fullPath, length, overwrite)
+	if status != 0 {
+		c.String(status, "file already exists")
+		return
+	}
+	if err := services.CheckUploadQuota(&project.User, addBytes, addFiles); err != nil {
+		writeTusQuotaError(c, err)
+		return
+	}
+
+	// The content is checked once it has been received
+	if _, err := services.CheckContentPolicy(&project.User, relativePath, length, nil); err != nil {
+		writeTusPolicyError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	session, err := services.CreateUploadSession(project.ID, userID.(uint), relativePath, length, overwrite)
+	if err != nil {
+		log.Printf("Failed to create upload session: %v", err)
+		c.String(http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/projects/%d/uploads/%s", project.ID, session.ID))
+	c.Header("Upload-Expires", services.GetUploadExpiry(session).UTC().Format(http.TimeFormat))
+
+	// Empty files are complete as soon as they are created
+	if length == 0 && !finalizeUpload(c, project, session) {
+		return
+	}
+
+	c.Status(http.StatusCreated)
+}
+
+// GetUploadOffset reports how many bytes of an upload have been received
+func GetUploadOffset(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	_, session, ok := getUploadSession(c)
+	if !ok {
+		return
+	}
+
+	offset, err := services.GetUploadOffset(session)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	c.Header("Upload-Expires", services.GetUploadExpiry(session).UTC().Format(http.TimeFormat))
+	c.Status(http.StatusOK)
+}
+
+// PatchUpload appends a chunk to an upload and moves the file into the
+// project once all bytes have been received
+func PatchUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.String(http.StatusUnsupportedMediaType, "invalid Content-Type")
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+
+	project, session, ok := getUploadSession(c)
+	if !ok {
+		return
+	}
+
+	newOffset, err := services.AppendUpload(session, offset, c.Request.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUploadOffsetMismatch):
+			c.String(http.StatusConflict, "Upload-Offset does not match current offset")
+		case errors.Is(err, services.ErrUploadBusy):
+			c.String(http.StatusLocked, "upload is in use")
+		default:
+			// Bytes received before the failure are kept for resuming
+			log.Printf("Failed to write upload %s: %v", session.ID, err)
+			c.String(http.StatusInternalServerError, "failed to write upload")
+		}
+		return
+	}
+
+	if newOffset == session.Length && !finalizeUpload(c, project, session) {
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	if newOffset > offset {
+		session.UpdatedAt = time.Now()
+	}
+	c.Header("Upload-Expires", services.GetUploadExpiry(session).UTC().Format(http.TimeFormat))
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteUpload cancels an upload and discards the received data
+func DeleteUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	_, session, ok := getUploadSession(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteUploadSession(session); err != nil {
+		c.String(http.StatusInternalServerError, "failed to delete upload")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// finalizeUpload re-checks the target and quota and checks the content
+// policy, then moves the completed upload into the project. It writes the
+// error response and returns false if the upload cannot be finalized.
+func finalizeUpload(c *gin.Context, project *models.Project, session *models.UploadSession) bool {
+	projectPath := project.GetStoragePath(project.User.Username)
+	fullPath := path.Join(projectPath, session.Path)
+
+	// The target may have changed while the upload was in progress
+	status, addBytes, addFiles := checkUploadTarget(fullPath, session.Length, session.Overwrite)
+	if status != 0 {
+		services.DeleteUploadSession(session)
+		c.String(status, "file already exists")
+		return false
+	}
+	if err := services.CheckQuota(&project.User, addBytes, addFiles); err != nil {
+		services.DeleteUploadSession(session)
+		writeTusQuotaError(c, err)
+		return false
+	}
+	if err := services.CheckUploadContentPolicy(&project.User, session); err != nil {
+		services.DeleteUploadSession(session)
+		writeTusPolicyError(c, err)
+		return false
+	}
+
+	if err := services.FinalizeUpload(session, fullPath); err != nil {
+		log.Printf("Failed to finalize upload %s: %v", session.ID, err)
+		c.String(http.StatusInternalServerError, "failed to store upload")
+		return false
+	}
+	return true
+}
+
+// checkUploadTarget returns a non-zero HTTP status if the upload target cannot
+// be written, and otherwise the quota delta of writing length bytes to it
+func checkUploadTarget(fullPath string, length int64, overwrite bool) (status int, addBytes, addFiles int64) {
+	existing, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		return 0, length, 1
+	}
+	if existing.IsDir || !overwrite {
+		return http.StatusConflict, 0, 0
+	}
+	return 0, length - existing.Size, 0
+}
+
+// getUploadProject loads the project of an upload request
+func getUploadProject(c *gin.Context) (*models.Project, bool) {
+	projectID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	var project models.Project
+	query := database.DB.Preload("User")
+
+	if !isAdmin.(bool) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.First(&project, projectID).Error; err != nil {
+		c.String(http.StatusNotFound, "project not found")
+		return nil, false
+	}
+	return &project, true
+}
+
+// getUploadSession loads the project and upload session of an upload request
+func getUploadSession(c *gin.Context) (*models.Project, *models.UploadSession, bool) {
+	project, ok := getUploadProject(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var session models.UploadSession
+	if err := database.DB.Where("id = ? AND project_id = ?", c.Param("upload_id"), project.ID).First(&session).Error; err != nil {
+		c.String(http.StatusNotFound, "upload not found")
+		return nil, nil, false
+	}
+	return project, &session, true
+}
+
+// checkTusVersion sets the Tus-Resumable response header and rejects
+// requests for an unsupported protocol version
+func checkTusVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.String(http.StatusPreconditionFailed, "unsupported tus version")
+		return false
+	}
+	return true
+}
+
+// writeTusQuotaError writes the response for a failed quota check
+func writeTusQuotaError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrQuotaExceeded) {
+		c.String(http.StatusForbidden, "storage quota exceeded")
+		return
+	}
+	c.String(http.StatusInternalServerError, "failed to check quota")
+}
+
+// writeTusPolicyError writes the response for a failed content policy check
+func writeTusPolicyError(c *gin.Context, err error) {
+	var policyErr *services.ContentPolicyError
+	if errors.As(err, &policyErr) {
+		c.String(http.StatusForbidden, policyErr.Error())
+		return
+	}
+	c.String(http.StatusInternalServerError, "failed to check content policy")
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma separated
+// pairs of a key and an optional base64 encoded value
+func parseUploadMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/itsHenry35/StaticForge/database"
 	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
 	"github.com/itsHenry35/StaticForge/types"
 	"github.com/itsHenry35/StaticForge/utils"
 )
@@ -24,6 +26,7 @@ func GetCurrentUser(c *gin.Context) {
 		Type:        user.Type,
 		IsActive:    user.IsActive,
 		CreatedAt:   user.CreatedAt.Format(time.RFC3339),
+		Usage:       getQuotaUsageResponse(&user),
 	})
 }
 
@@ -164,6 +167,7 @@ func GetUsers(c *gin.Context) {
 			Type:        user.Type,
 			IsActive:    user.IsActive,
 			CreatedAt:   user.CreatedAt.Format(time.RFC3339),
+			Usage:       getQuotaUsageResponse(&user),
 		})
 	}
 
@@ -188,6 +192,7 @@ func GetUserByID(c *gin.Context) {
 		Type:        user.Type,
 		IsActive:    user.IsActive,
 		CreatedAt:   user.CreatedAt.Format(time.RFC3339),
+		Usage:       getQuotaUsageResponse(&user),
 	})
 }
 
@@ -310,12 +315,26 @@ func DeleteUser(c *gin.Context) {
 	database.DB.Where("user_id = ?", userID).Find(&projects)
 
 	for _, project := range projects {
-		// Delete project files from disk
-		projectPath := project.GetPath(
-			"data/projects",
-			user.Username,
-		)
-		utils.DeleteDir(projectPath)
+		// Delete project files from storage
+		storage.Store.Delete(project.GetStoragePath(user.Username))
+
+		// Delete deployments
+		services.DeleteProjectDeployments(project.ID)
+
+		// Delete file history
+		services.DeleteProjectRevisions(project.ID)
+
+		// Delete unfinished uploads
+		services.DeleteProjectUploads(project.ID)
+
+		// Delete trash
+		services.DeleteProjectTrash(project.ID)
+
+		// Delete git repository and deploy tokens
+		services.DeleteProjectGit(project.ID)
+
+		// Delete pending transfers
+		services.DeleteProjectTransfers(project.ID)
 
 		// Delete analytics
 		database.DB.Where("project_id = ?", project.ID).Delete(&models.Analytics{})
@@ -324,6 +343,12 @@ func DeleteUser(c *gin.Context) {
 		database.DB.Delete(&project)
 	}
 
+	// Delete SSH keys
+	database.DB.Where("user_id = ?", user.ID).Delete(&models.SSHKey{})
+
+	// Delete transfers offered to the user
+	database.DB.Where("to_user_id = ?", user.ID).Delete(&models.ProjectTransfer{})
+
 	// Delete user
 	if err := database.DB.Delete(&user).Error; err != nil {
 		utils.InternalServerError(c, utils.MsgUserDeleteFailed)
@@ -16,7 +16,12 @@ import (
 // SetupRoutes sets up all application routes
 func SetupRoutes(r *gin.Engine, staticFS embed.FS) {
 	// Apply global middleware
-	r.Use(gzip.Gzip(gzip.DefaultCompression))
+	// Git packs are already compressed, and WebDAV clients and raw file
+	// downloads use range requests, so their responses are sent as is
+	r.Use(gzip.Gzip(gzip.DefaultCompression,
+		gzip.WithExcludedPaths([]string{"/git/", "/dav/"}),
+		gzip.WithExcludedPathsRegexs([]string{`^/api/projects/[^/]+/files/raw$`}),
+	))
 	r.Use(middlewares.CORSMiddleware())
 	r.Use(middlewares.LoggerMiddleware())
 	r.Use(middlewares.SecurityHeadersMiddleware())
@@ -44,6 +49,12 @@ func SetupRoutes(r *gin.Engine, staticFS embed.FS) {
 		// Public project info (for consent page)
 		api.GET("/projects/public/:name", handlers.GetPublicProjectInfo)
 
+		// Project template previews (shown when choosing a template)
+		api.GET("/templates/:name/preview", handlers.GetProjectTemplatePreview)
+
+		// Git deploy hook (authenticated by the secret token in the URL)
+		api.POST("/hooks/git/:token", handlers.GitDeployHook)
+
 		// Protected routes (require authentication)
 		protected := api.Group("")
 		protected.Use(middlewares.AuthMiddleware())
@@ -54,8 +65,17 @@ func SetupRoutes(r *gin.Engine, staticFS embed.FS) {
 				user.GET("/me", handlers.GetCurrentUser)
 				user.PUT("/me", handlers.UpdateCurrentUser)
 				user.POST("/change-password", handlers.ChangePassword)
+				user.GET("/ssh-keys", handlers.GetSSHKeys)
+				user.POST("/ssh-keys", handlers.AddSSHKey)
+				user.DELETE("/ssh-keys/:key_id", handlers.DeleteSSHKey)
+				user.GET("/transfers", handlers.GetIncomingTransfers)
+				user.POST("/transfers/:transfer_id/accept", handlers.AcceptProjectTransfer)
+				user.POST("/transfers/:transfer_id/decline", handlers.DeclineProjectTransfer)
 			}
 
+			// Project templates
+			protected.GET("/templates", handlers.GetProjectTemplates)
+
 			// Projects
 			projects := protected.Group("/projects")
 			{
@@ -63,19 +83,64 @@ func SetupRoutes(r *gin.Engine, staticFS embed.FS) {
 				projects.POST("", handlers.CreateProject)
 				projects.GET("/:id", handlers.GetProjectByID)
 				projects.PUT("/:id", handlers.UpdateProject)
+				projects.POST("/:id/fork", handlers.ForkProject)
 				projects.POST("/:id/publish", handlers.PublishProject)
+				projects.GET("/:id/deployments", handlers.GetProjectDeployments)
+				projects.POST("/:id/deployments/:deployment_id/rollback", handlers.RollbackDeployment)
 				projects.DELETE("/:id", handlers.DeleteProject)
 
+				// Ownership transfer
+				projects.GET("/:id/transfer", handlers.GetProjectTransfer)
+				projects.POST("/:id/transfer", handlers.RequestProjectTransfer)
+				projects.DELETE("/:id/transfer", handlers.CancelProjectTransfer)
+
 				// Files (filesystem-based)
 				projects.GET("/:id/files", handlers.ScanProjectFiles)
 				projects.POST("/:id/files/upload", handlers.UploadFile)
+				projects.POST("/:id/files/upload-multiple", handlers.UploadFiles)
 				projects.GET("/:id/files/content", handlers.GetFileContentByPath)
+				projects.GET("/:id/files/raw", handlers.DownloadFileByPath)
 				projects.PUT("/:id/files/content", handlers.UpdateFileContentByPath)
 				projects.POST("/:id/files/rename", handlers.RenameFileByPath)
 				projects.POST("/:id/files/move", handlers.MoveFileByPath)
+				projects.POST("/:id/files/copy", handlers.CopyFileByPath)
 				projects.DELETE("/:id/files/delete", handlers.DeleteFileByPath)
+				projects.POST("/:id/files/batch", handlers.BatchFileOperations)
+				projects.GET("/:id/files/search", handlers.SearchProjectFiles)
+				projects.POST("/:id/files/replace", handlers.ReplaceInProjectFiles)
 				projects.POST("/:id/folders", handlers.CreateFolder)
 
+				// Collaborative editing (WebSocket)
+				projects.GET("/:id/collab", handlers.CollabEditFile)
+				projects.GET("/:id/collab/presence", handlers.GetCollabPresence)
+
+				// Resumable uploads (tus)
+				projects.OPTIONS("/:id/uploads", handlers.TusOptions)
+				projects.POST("/:id/uploads", handlers.CreateUpload)
+				projects.HEAD("/:id/uploads/:upload_id", handlers.GetUploadOffset)
+				projects.PATCH("/:id/uploads/:upload_id", handlers.PatchUpload)
+				projects.DELETE("/:id/uploads/:upload_id", handlers.DeleteUpload)
+
+				projects.POST("/:id/import", handlers.ImportProjectArchive)
+				projects.GET("/:id/export", handlers.ExportProject)
+
+				// File history
+				projects.GET("/:id/files/history", handlers.GetFileHistory)
+				projects.GET("/:id/files/history/diff", handlers.GetFileHistoryDiff)
+				projects.POST("/:id/files/history/restore", handlers.RestoreFileRevision)
+
+				// Trash
+				projects.GET("/:id/trash", handlers.GetProjectTrash)
+				projects.POST("/:id/trash/:trash_id/restore", handlers.RestoreTrashItem)
+				projects.DELETE("/:id/trash/:trash_id", handlers.PurgeTrashItem)
+				projects.DELETE("/:id/trash", handlers.EmptyProjectTrash)
+
+				// Git
+				projects.POST("/:id/git/sync", handlers.SyncProjectGit)
+				projects.GET("/:id/deploy-tokens", handlers.GetDeployTokens)
+				projects.POST("/:id/deploy-tokens", handlers.CreateDeployToken)
+				projects.DELETE("/:id/deploy-tokens/:token_id", handlers.DeleteDeployToken)
+
 				// Analytics
 				projects.GET("/:id/analytics", handlers.GetProjectAnalytics)
 			}
@@ -92,12 +157,18 @@ func SetupRoutes(r *gin.Engine, staticFS embed.FS) {
 			admin.PUT("/users/:id", handlers.UpdateUser)
 			admin.POST("/users/:id/toggle-status", handlers.ToggleUserStatus)
 			admin.POST("/users/:id/set-type", handlers.SetUserType)
+			admin.POST("/users/:id/set-quota", handlers.SetUserQuota)
 			admin.DELETE("/users/:id", handlers.DeleteUser)
 
 			// Project management
 			admin.GET("/projects", handlers.GetAllProjects)
 			admin.PUT("/projects/:id", handlers.UpdateProject)
 			admin.POST("/projects/:id/toggle-status", handlers.ToggleProjectStatus)
+			admin.GET("/projects/:id/export", handlers.ExportProject)
+
+			// Project templates
+			admin.POST("/templates", handlers.UploadProjectTemplate)
+			admin.DELETE("/templates/:name", handlers.DeleteProjectTemplate)
 
 			// Config management
 			admin.GET("/config", handlers.GetConfig)
@@ -114,6 +185,21 @@ func SetupRoutes(r *gin.Engine, staticFS embed.FS) {
 	{
 		preview.GET("/projects/:id/preview", handlers.PreviewProject)
 		preview.GET("/projects/:id/preview/*filepath", handlers.PreviewProject)
+		preview.GET("/projects/:id/preview-events", handlers.PreviewFileEvents)
+	}
+
+	// Git smart HTTP (authenticated by the handlers with basic auth, deploy tokens or sessions)
+	git := r.Group("/git/:repo")
+	{
+		git.GET("/info/refs", handlers.GitInfoRefs)
+		git.POST("/git-upload-pack", handlers.GitUploadPack)
+		git.POST("/git-receive-pack", handlers.GitReceivePack)
+	}
+
+	// WebDAV access to project files (authenticated by the handler with basic auth)
+	for _, method := range handlers.DAVMethods {
+		r.Handle(method, "/dav/:project", handlers.ServeWebDAV)
+		r.Handle(method, "/dav/:project/*path", handlers.ServeWebDAV)
 	}
 
 	// Static website serving (automatically records visits)
@@ -194,8 +280,10 @@ func SetupRoutes(r *gin.Engine, staticFS embed.FS) {
 
 	// All other routes serve index.html (SPA)
 	r.NoRoute(func(c *gin.Context) {
-		// Don't serve index.html for API or static site routes
+		// Don't serve index.html for API, git, WebDAV or static site routes
 		if strings.HasPrefix(c.Request.URL.Path, "/api/") ||
+			strings.HasPrefix(c.Request.URL.Path, "/git/") ||
+			strings.HasPrefix(c.Request.URL.Path, "/dav/") ||
 			strings.HasPrefix(c.Request.URL.Path, "/s/") {
 			c.Status(http.StatusNotFound)
 			return
@@ -13,20 +13,27 @@ import (
 )
 
 type Config struct {
-	Server              ServerConfig      `json:"server"`
-	Database            DatabaseConfig    `json:"database"`
-	Redis               RedisConfig       `json:"redis"`
-	JWT                 JWTConfig         `json:"jwt"`
-	OAuth               []OAuthConfig     `json:"oauth"`
-	Upload              UploadConfig      `json:"upload"`
-	AllowRegister       bool              `json:"allow_register"`
-	Replacements        []ReplacementRule `json:"replacements"`
-	AllowedIframeOrigin string            `json:"allowed_iframe_origin"` // Allowed origins for iframe embedding (* for all, empty for none)
-	LogoURL             string            `json:"logo_url"`
-	SiteName            string            `json:"site_name"`
-	SiteHost            string            `json:"site_host"`   // Main site host (e.g. example.com)
-	SecureHost          string            `json:"secure_host"` // Embed-only host: only serves trusted sites, blocks management pages
-	mu                  sync.RWMutex      `json:"-"`
+	Server              ServerConfig        `json:"server"`
+	Database            DatabaseConfig      `json:"database"`
+	Redis               RedisConfig         `json:"redis"`
+	JWT                 JWTConfig           `json:"jwt"`
+	OAuth               []OAuthConfig       `json:"oauth"`
+	Upload              UploadConfig        `json:"upload"`
+	History             HistoryConfig       `json:"history"`
+	Deployments         DeploymentsConfig   `json:"deployments"`
+	Trash               TrashConfig         `json:"trash"`
+	Git                 GitConfig           `json:"git"`
+	SFTP                SFTPConfig          `json:"sftp"`
+	Quota               QuotaConfig         `json:"quota"`
+	ContentPolicy       ContentPolicyConfig `json:"content_policy"`
+	AllowRegister       bool                `json:"allow_register"`
+	Replacements        []ReplacementRule   `json:"replacements"`
+	AllowedIframeOrigin string              `json:"allowed_iframe_origin"` // Allowed origins for iframe embedding (* for all, empty for none)
+	LogoURL             string              `json:"logo_url"`
+	SiteName            string              `json:"site_name"`
+	SiteHost            string              `json:"site_host"`   // Main site host (e.g. example.com)
+	SecureHost          string              `json:"secure_host"` // Embed-only host: only serves trusted sites, blocks management pages
+	mu                  sync.RWMutex        `json:"-"`
 }
 
 type ReplacementRule struct {
@@ -82,10 +89,96 @@ type OAuthConfig struct {
 }
 
 type UploadConfig struct {
-	MaxSize int64  `json:"max_size"` // bytes
-	DataDir string `json:"data_dir"`
+	MaxSize    int64         `json:"max_size"` // bytes
+	DataDir    string        `json:"data_dir"`
+	StagingDir string        `json:"staging_dir"` // local folder for partial resumable uploads
+	Storage    StorageConfig `json:"storage"`
 }
 
+type StorageConfig struct {
+	Type string   `json:"type"` // local, s3
+	S3   S3Config `json:"s3"`
+}
+
+type S3Config struct {
+	Endpoint  string `json:"endpoint"` // host[:port], e.g. localhost:9000 for MinIO
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	UseSSL    bool   `json:"use_ssl"`
+	Prefix    string `json:"prefix"` // optional key prefix inside the bucket
+}
+
+type HistoryConfig struct {
+	MaxRevisions int `json:"max_revisions"` // revisions kept per file, 0 for unlimited
+	MaxAgeDays   int `json:"max_age_days"`  // revisions older than this are pruned, 0 to keep forever
+}
+
+type DeploymentsConfig struct {
+	MaxDeployments int `json:"max_deployments"` // snapshots kept per project, 0 for unlimited
+	MaxAgeDays     int `json:"max_age_days"`    // older snapshots are pruned, 0 to keep forever
+}
+
+type TrashConfig struct {
+	RetentionDays int `json:"retention_days"` // deleted files are purged after this many days, 0 to keep until emptied
+}
+
+type GitConfig struct {
+	RepoDir             string `json:"repo_dir"`              // local folder holding one bare repository per project
+	AllowFileRemotes    bool   `json:"allow_file_remotes"`    // allow syncing from file:// remotes, which can read any repository on the server
+	AllowPrivateRemotes bool   `json:"allow_private_remotes"` // allow remotes on loopback, private and link-local addresses
+}
+
+type SFTPConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	HostKeyPath string `json:"host_key_path"` // private host key, generated on first start if missing
+}
+
+type QuotaConfig struct {
+	Normal   QuotaLimit `json:"normal"`
+	Verified QuotaLimit `json:"verified"`
+	Admin    QuotaLimit `json:"admin"`
+}
+
+type QuotaLimit struct {
+	MaxBytes int64 `json:"max_bytes"` // total size of all projects, 0 for unlimited
+	MaxFiles int64 `json:"max_files"` // total number of files, 0 for unlimited
+}
+
+type ContentPolicyConfig struct {
+	Normal   ContentPolicy `json:"normal"`
+	Verified ContentPolicy `json:"verified"`
+	Admin    ContentPolicy `json:"admin"`
+}
+
+// ContentPolicy restricts the files users of a type can write. Extensions
+// include the dot and are matched case-insensitively.
+type ContentPolicy struct {
+	AllowedExtensions []string         `json:"allowed_extensions"` // empty allows every extension not denied
+	DeniedExtensions  []string         `json:"denied_extensions"`
+	VerifyMimeType    bool             `json:"verify_mime_type"` // block files whose content does not match their extension
+	MaxSizes          map[string]int64 `json:"max_sizes"`        // bytes, by extension (".mp4"), MIME type ("image/png") or MIME group ("video/*")
+	XSSCheck          string           `json:"xss_check"`        // action for scripts reading cookies or storage in HTML and JS, empty to skip
+	ContentRules      []ContentRule    `json:"content_rules"`
+}
+
+// ContentRule matches the content of text files against a regular expression
+type ContentRule struct {
+	Name       string   `json:"name"`
+	Pattern    string   `json:"pattern"`
+	Extensions []string `json:"extensions"` // files the rule applies to, empty for all text files
+	Action     string   `json:"action"`     // warn or block
+}
+
+// Content policy actions
+const (
+	PolicyActionWarn  = "warn"
+	PolicyActionBlock = "block"
+)
+
 var (
 	AppConfig *Config
 	once      sync.Once
@@ -160,6 +253,9 @@ func createDefaultConfig(path string) error {
 		return fmt.Errorf("failed to generate JWT secret: %w", err)
 	}
 
+	// Server-side scripts and executables have no place on a static site
+	deniedExtensions := []string{".php", ".phtml", ".asp", ".aspx", ".jsp", ".cgi", ".exe", ".dll", ".bat", ".cmd", ".com", ".scr", ".msi"}
+
 	defaultConfig := &Config{
 		Server: ServerConfig{
 			Host: "0.0.0.0",
@@ -186,8 +282,49 @@ func createDefaultConfig(path string) error {
 		},
 		OAuth:         []OAuthConfig{},
 		Upload: UploadConfig{
-			MaxSize: 100 * 1024 * 1024, // 100MB
-			DataDir: "data/projects",
+			MaxSize:    100 * 1024 * 1024, // 100MB
+			DataDir:    "data/projects",
+			StagingDir: "data/uploads",
+			Storage: StorageConfig{
+				Type: "local",
+			},
+		},
+		History: HistoryConfig{
+			MaxRevisions: 50,
+			MaxAgeDays:   90,
+		},
+		Deployments: DeploymentsConfig{
+			MaxDeployments: 20,
+			MaxAgeDays:     90,
+		},
+		Trash: TrashConfig{
+			RetentionDays: 30,
+		},
+		Git: GitConfig{
+			RepoDir: "data/git",
+		},
+		SFTP: SFTPConfig{
+			Enabled:     false,
+			Host:        "0.0.0.0",
+			Port:        2222,
+			HostKeyPath: "data/sftp_host_key",
+		},
+		Quota: QuotaConfig{
+			Normal:   QuotaLimit{MaxBytes: 500 * 1024 * 1024, MaxFiles: 10000},
+			Verified: QuotaLimit{MaxBytes: 2 * 1024 * 1024 * 1024, MaxFiles: 50000},
+			Admin:    QuotaLimit{},
+		},
+		ContentPolicy: ContentPolicyConfig{
+			Normal: ContentPolicy{
+				DeniedExtensions: deniedExtensions,
+				VerifyMimeType:   true,
+				XSSCheck:         PolicyActionWarn,
+			},
+			Verified: ContentPolicy{
+				DeniedExtensions: deniedExtensions,
+				VerifyMimeType:   true,
+			},
+			Admin: ContentPolicy{},
 		},
 		AllowRegister:       true,
 		Replacements:        []ReplacementRule{},
@@ -233,6 +370,14 @@ func (c *Config) GetServerAddr() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
 
+// GetSFTPAddr returns SFTP server address string
+func (c *Config) GetSFTPAddr() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return fmt.Sprintf("%s:%d", c.SFTP.Host, c.SFTP.Port)
+}
+
 // AddOAuthProvider adds a new OAuth provider
 func (c *Config) AddOAuthProvider(provider OAuthConfig) error {
 	c.mu.Lock()
@@ -85,6 +85,14 @@ func AutoMigrate() error {
 		&models.User{},
 		&models.Project{},
 		&models.Analytics{},
+		&models.Deployment{},
+		&models.FileRevision{},
+		&models.UploadSession{},
+		&models.TrashItem{},
+		&models.DeployToken{},
+		&models.SSHKey{},
+		&models.ProjectTemplate{},
+		&models.ProjectTransfer{},
 	)
 }
 
@@ -15,6 +15,7 @@ import (
 	"github.com/itsHenry35/StaticForge/database"
 	"github.com/itsHenry35/StaticForge/models"
 	"github.com/itsHenry35/StaticForge/services"
+	"github.com/itsHenry35/StaticForge/storage"
 	"github.com/itsHenry35/StaticForge/utils"
 )
 
@@ -48,6 +49,11 @@ func main() {
 		log.Fatalf("Failed to initialize Redis: %v", err)
 	}
 
+	// Initialize project file storage
+	if err := storage.InitStorage(cfg); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
 	// Initialize admin account on first startup
 	if err := initializeAdminAccount(); err != nil {
 		log.Fatalf("Failed to initialize admin account: %v", err)
@@ -56,17 +62,29 @@ func main() {
 	// Start analytics flush worker
 	go startAnalyticsFlushWorker()
 
+	// Start expired upload cleanup worker
+	go startUploadCleanupWorker()
+
+	// Start trash retention worker
+	go startTrashCleanupWorker()
+
+	// Start deployment cleanup worker
+	go startDeploymentCleanupWorker()
+
+	// Start file history retention worker
+	go startHistoryCleanupWorker()
+
+	// Start SFTP server
+	if cfg.SFTP.Enabled {
+		go startSFTPServer(cfg)
+	}
+
 	// Create Gin router
 	r := gin.Default()
 
 	// Setup routes with embedded static files
 	routes.SetupRoutes(r, StaticFiles)
 
-	// Ensure data directory exists
-	if err := utils.EnsureDir(cfg.Upload.DataDir); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
-	}
-
 	// Handle graceful shutdown
 	go func() {
 		sigChan := make(chan os.Signal, 1)
@@ -158,3 +176,58 @@ func startAnalyticsFlushWorker() {
 		}
 	}
 }
+
+// startUploadCleanupWorker starts a background worker to remove abandoned resumable uploads
+func startUploadCleanupWorker() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := services.CleanupExpiredUploads(); err != nil {
+			log.Printf("Error cleaning up expired uploads: %v", err)
+		}
+	}
+}
+
+// startTrashCleanupWorker starts a background worker to purge trash items past their retention
+func startTrashCleanupWorker() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := services.CleanupExpiredTrash(); err != nil {
+			log.Printf("Error purging expired trash: %v", err)
+		}
+	}
+}
+
+// startDeploymentCleanupWorker starts a background worker to remove deployments past their retention
+func startDeploymentCleanupWorker() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := services.CleanupExpiredDeployments(); err != nil {
+			log.Printf("Error removing expired deployments: %v", err)
+		}
+	}
+}
+
+// startHistoryCleanupWorker starts a background worker to prune file revisions past their retention
+func startHistoryCleanupWorker() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := services.CleanupExpiredRevisions(); err != nil {
+			log.Printf("Error pruning expired revisions: %v", err)
+		}
+	}
+}
+
+// startSFTPServer serves project files over SFTP until the listener fails
+func startSFTPServer(cfg *config.Config) {
+	if err := services.StartSFTPServer(cfg); err != nil {
+		log.Printf("Error running SFTP server: %v", err)
+	}
+}
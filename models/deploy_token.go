@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// DeployToken grants git access to a single project, so CI jobs can push
+// without a user's password or session
+type DeployToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ProjectID  uint       `gorm:"not null;index" json:"project_id"`
+	Name       string     `gorm:"not null;size:100" json:"name"`
+	TokenHash  string     `gorm:"not null;uniqueIndex;size:64" json:"-"` // SHA-256 of the token, which is only shown once
+	CreatedBy  uint       `gorm:"not null" json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+
+	// Relations
+	Project Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+}
+
+// TableName specifies the table name for DeployToken model
+func (DeployToken) TableName() string {
+	return "deploy_tokens"
+}
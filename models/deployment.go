@@ -0,0 +1,35 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeploymentsRoot is the storage folder holding all deployment snapshots.
+// Usernames cannot start with a dot, so it never clashes with user folders.
+const DeploymentsRoot = ".deployments"
+
+type Deployment struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID uint  `gorm:"not null;uniqueIndex:idx_project_number" json:"project_id"`
+	Number    int   `gorm:"not null;uniqueIndex:idx_project_number" json:"number"`
+	CreatedBy uint  `gorm:"not null" json:"created_by"`
+	FileCount int   `gorm:"default:0" json:"file_count"`
+	TotalSize int64 `gorm:"default:0" json:"total_size"`
+
+	// Relations
+	Project Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+}
+
+// TableName specifies the table name for Deployment model
+func (Deployment) TableName() string {
+	return "deployments"
+}
+
+// GetStoragePath returns the storage key prefix of this deployment's snapshot
+func (d *Deployment) GetStoragePath() string {
+	return fmt.Sprintf("%s/%d/%d", DeploymentsRoot, d.ProjectID, d.Number)
+}
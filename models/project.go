@@ -1,11 +1,19 @@
 package models
 
 import (
+	"net/url"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Git sync statuses
+const (
+	GitSyncRunning = "syncing"
+	GitSyncSuccess = "success"
+	GitSyncFailed  = "failed"
+)
+
 type Project struct {
 	ID        uint      `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -20,6 +28,23 @@ type Project struct {
 	IsSecure    bool   `gorm:"default:true" json:"is_secure"`
 	Password    string `gorm:"size:255" json:"-"` // bcrypt hash for access password
 	HasPassword bool   `gorm:"default:false" json:"has_password"`
+	AllowFork   bool   `gorm:"default:false" json:"allow_fork"` // other users may fork the published site
+
+	ForkedFromID *uint `gorm:"index" json:"forked_from_id"` // project this one was forked from
+
+	ActiveDeploymentID *uint `gorm:"index" json:"active_deployment_id"` // deployment served at /s/{name}/
+
+	// Git push-to-deploy
+	GitBranch      string `gorm:"size:255;default:main" json:"git_branch"` // branch mapped to the working tree
+	GitAutoPublish bool   `gorm:"default:false" json:"git_auto_publish"`   // publish after every push to GitBranch
+	GitCommit      string `gorm:"size:40" json:"git_commit"`               // last commit synced to the working tree
+
+	// Git remote sync
+	GitRemoteURL  string     `gorm:"size:1024" json:"git_remote_url"` // repository pulled by git syncs, empty when not linked
+	GitHookToken  string     `gorm:"size:64;index" json:"-"`          // secret of the inbound deploy hook URL
+	GitSyncStatus string     `gorm:"size:20" json:"git_sync_status"`  // status of the last sync from the remote
+	GitSyncError  string     `gorm:"type:text" json:"git_sync_error"` // error of the last failed sync
+	GitSyncedAt   *time.Time `json:"git_synced_at"`                   // end of the last sync
 
 	// Relations
 	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -35,10 +60,22 @@ func (p *Project) BeforeCreate(tx *gorm.DB) error {
 	if p.DisplayName == "" {
 		p.DisplayName = p.Name
 	}
+	if p.GitBranch == "" {
+		p.GitBranch = "main"
+	}
 	return nil
 }
 
-// GetPath returns the file system path for this project
-func (p *Project) GetPath(dataDir string, username string) string {
-	return dataDir + "/" + username + "/" + p.Name
+// GetStoragePath returns the storage key prefix for this project
+func (p *Project) GetStoragePath(username string) string {
+	return username + "/" + p.Name
+}
+
+// GetRedactedGitRemoteURL returns the git remote URL with any password masked
+func (p *Project) GetRedactedGitRemoteURL() string {
+	u, err := url.Parse(p.GitRemoteURL)
+	if err != nil || u.User == nil {
+		return p.GitRemoteURL
+	}
+	return u.Redacted()
 }
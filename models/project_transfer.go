@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ProjectTransfer is a pending handover of a project to another user. It
+// takes effect when the recipient accepts it.
+type ProjectTransfer struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ProjectID  uint `gorm:"not null;uniqueIndex" json:"project_id"` // one pending transfer per project
+	FromUserID uint `gorm:"not null;index" json:"from_user_id"`     // owner when the transfer was requested
+	ToUserID   uint `gorm:"not null;index" json:"to_user_id"`
+	CreatedBy  uint `gorm:"not null" json:"created_by"` // owner or admin who requested the transfer
+
+	// Relations
+	Project  Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	FromUser User    `gorm:"foreignKey:FromUserID" json:"from_user,omitempty"`
+	ToUser   User    `gorm:"foreignKey:ToUserID" json:"to_user,omitempty"`
+}
+
+// TableName specifies the table name for ProjectTransfer model
+func (ProjectTransfer) TableName() string {
+	return "project_transfers"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// HistoryRoot is the storage folder holding file revision contents
+const HistoryRoot = ".history"
+
+type FileRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ProjectID uint   `gorm:"not null;index:idx_revision_project_path" json:"project_id"`
+	Path      string `gorm:"not null;size:512;index:idx_revision_project_path" json:"path"`
+	Hash      string `gorm:"not null;size:64" json:"hash"` // SHA-256 of the content
+	Size      int64  `gorm:"default:0" json:"size"`
+	UserID    uint   `gorm:"not null" json:"user_id"` // author of this revision
+
+	// Relations
+	Project Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+}
+
+// TableName specifies the table name for FileRevision model
+func (FileRevision) TableName() string {
+	return "file_revisions"
+}
+
+// GetStoragePath returns the storage key of this revision's content.
+// Contents are addressed by hash, so identical revisions share one blob.
+func (r *FileRevision) GetStoragePath() string {
+	return fmt.Sprintf("%s/%d/%s", HistoryRoot, r.ProjectID, r.Hash)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// SSHKey is a public key a user can log in to the SFTP server with
+type SSHKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Name        string     `gorm:"not null;size:100" json:"name"`
+	PublicKey   string     `gorm:"type:text;not null" json:"public_key"`            // authorized_keys format, without comment
+	Fingerprint string     `gorm:"not null;uniqueIndex;size:64" json:"fingerprint"` // SHA256 fingerprint, as shown by ssh-keygen -l
+	LastUsedAt  *time.Time `json:"last_used_at"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// TableName specifies the table name for SSHKey model
+func (SSHKey) TableName() string {
+	return "ssh_keys"
+}
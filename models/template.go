@@ -0,0 +1,42 @@
+package models
+
+import (
+	"path"
+	"time"
+)
+
+// TemplatesRoot is the storage folder holding the files of uploaded project
+// templates. Usernames cannot start with a dot, so it never clashes with
+// user folders.
+const TemplatesRoot = ".templates"
+
+// ProjectTemplate is a project template uploaded by an admin. Built-in
+// templates are embedded in the binary and have no record.
+type ProjectTemplate struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name        string `gorm:"uniqueIndex;not null;size:100" json:"name"`
+	DisplayName string `gorm:"not null;size:100" json:"display_name"`
+	Description string `gorm:"type:text" json:"description"`
+	PreviewName string `gorm:"size:100" json:"-"` // file name of the preview image, empty if there is none
+	FileCount   int64  `gorm:"default:0" json:"file_count"`
+	Size        int64  `gorm:"default:0" json:"size"`
+	CreatedBy   uint   `gorm:"not null" json:"created_by"`
+}
+
+// TableName specifies the table name for ProjectTemplate model
+func (ProjectTemplate) TableName() string {
+	return "project_templates"
+}
+
+// IsBuiltIn reports whether the template is embedded in the binary
+func (t *ProjectTemplate) IsBuiltIn() bool {
+	return t.ID == 0
+}
+
+// GetStoragePath returns the storage folder of an uploaded template
+func (t *ProjectTemplate) GetStoragePath() string {
+	return path.Join(TemplatesRoot, t.Name)
+}
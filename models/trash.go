@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrashRoot is the storage folder holding deleted project files until they
+// are restored or purged
+const TrashRoot = ".trash"
+
+type TrashItem struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"` // deletion time
+
+	ProjectID    uint   `gorm:"not null;index" json:"project_id"`
+	OriginalPath string `gorm:"not null;size:512" json:"original_path"`
+	StorageKey   string `gorm:"not null;size:32" json:"-"`
+	IsDir        bool   `gorm:"default:false" json:"is_dir"`
+	Size         int64  `gorm:"default:0" json:"size"`
+	FileCount    int64  `gorm:"default:0" json:"file_count"`
+	DeletedBy    uint   `gorm:"not null" json:"deleted_by"`
+
+	// Relations
+	Project       Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	DeletedByUser User    `gorm:"foreignKey:DeletedBy" json:"deleted_by_user,omitempty"`
+}
+
+// TableName specifies the table name for TrashItem model
+func (TrashItem) TableName() string {
+	return "trash_items"
+}
+
+// GetStoragePath returns the storage key of the deleted file or folder
+func (t *TrashItem) GetStoragePath() string {
+	return fmt.Sprintf("%s/%d/%s", TrashRoot, t.ProjectID, t.StorageKey)
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// UploadSession is a resumable (tus) upload in progress. The received bytes
+// are kept in a local staging file until the upload is complete.
+type UploadSession struct {
+	ID        string    `gorm:"primarykey;size:32" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID uint   `gorm:"not null;index" json:"project_id"`
+	UserID    uint   `gorm:"not null" json:"user_id"`
+	Path      string `gorm:"not null;size:512" json:"path"` // target path relative to the project root
+	Length    int64  `gorm:"not null" json:"length"`        // total size announced by Upload-Length
+	Overwrite bool   `gorm:"default:false" json:"overwrite"`
+
+	// Relations
+	Project Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+}
+
+// TableName specifies the table name for UploadSession model
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
@@ -18,8 +18,13 @@ type User struct {
 	Type        string `gorm:"type:varchar(20);default:'normal'" json:"type"` // normal, verified, admin
 	IsActive    bool   `gorm:"default:true" json:"is_active"`
 
+	// Per-user quota overrides, nil to use the default for the user type
+	QuotaBytes *int64 `json:"quota_bytes"`
+	QuotaFiles *int64 `json:"quota_files"`
+
 	// Relations
 	Projects []Project `gorm:"foreignKey:UserID" json:"projects,omitempty"`
+	SSHKeys  []SSHKey  `gorm:"foreignKey:UserID" json:"ssh_keys,omitempty"`
 }
 
 // TableName specifies the table name for User model
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// ErrInvalidContentPolicy is returned for policies with unknown actions or
+// patterns that do not compile
+var ErrInvalidContentPolicy = errors.New("invalid content policy")
+
+// policyScanSize is how much of a file is read to check its content; larger
+// files are only checked up to this size
+const policyScanSize = 2 << 20
+
+// Rules a file can break
+const (
+	PolicyRuleExtension = "extension"
+	PolicyRuleMimeType  = "mime_type"
+	PolicyRuleMaxSize   = "max_size"
+	PolicyRuleXSS       = "xss"
+	PolicyRuleContent   = "content"
+)
+
+// PolicyViolation is a rule of the content policy that a file breaks
+type PolicyViolation struct {
+	Path   string
+	Rule   string
+	Action string
+	Name   string // name of the content rule
+	Detail string // detected MIME type or size limit
+}
+
+// ContentPolicyError is returned for a file that breaks a blocking rule. It
+// lists all violations of the file, including warnings.
+type ContentPolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *ContentPolicyError) Error() string {
+	for _, v := range e.Violations {
+		if v.Action == config.PolicyActionBlock {
+			return fmt.Sprintf("%s breaks the %s rule of the content policy", v.Path, v.Rule)
+		}
+	}
+	return "content policy violation"
+}
+
+// policyPatterns caches the compiled patterns of content rules
+var policyPatterns sync.Map
+
+// GetContentPolicy returns the content policy for the type of a user
+func GetContentPolicy(user *models.User) config.ContentPolicy {
+	cfg := config.GetConfig()
+
+	switch {
+	case user.IsAdmin():
+		return cfg.ContentPolicy.Admin
+	case user.IsVerified():
+		return cfg.ContentPolicy.Verified
+	default:
+		return cfg.ContentPolicy.Normal
+	}
+}
+
+// ValidateContentPolicy checks that the actions and patterns of a policy
+// are valid
+func ValidateContentPolicy(policy config.ContentPolicy) error {
+	if policy.XSSCheck != "" && !isPolicyAction(policy.XSSCheck) {
+		return ErrInvalidContentPolicy
+	}
+	for key, limit := range policy.MaxSizes {
+		if key == "" || limit < 0 {
+			return ErrInvalidContentPolicy
+		}
+	}
+	for _, rule := range policy.ContentRules {
+		if !isPolicyAction(rule.Action) {
+			return ErrInvalidContentPolicy
+		}
+		if _, err := compilePolicyPattern(rule.Pattern); err != nil {
+			return ErrInvalidContentPolicy
+		}
+	}
+	return nil
+}
+
+// CheckContentPolicy checks a file about to be written to relPath against
+// the content policy of the project owner. Up to policyScanSize bytes of
+// content are read; content may be nil when only the name and size are
+// known yet. The warnings are returned, or a *ContentPolicyError if a
+// blocking rule is broken.
+func CheckContentPolicy(owner *models.User, relPath string, size int64, content io.Reader) ([]PolicyViolation, error) {
+	policy := GetContentPolicy(owner)
+	name := path.Base(relPath)
+
+	var violations []PolicyViolation
+	add := func(rule, action, ruleName, detail string) {
+		violations = append(violations, PolicyViolation{
+			Path:   relPath,
+			Rule:   rule,
+			Action: action,
+			Name:   ruleName,
+			Detail: detail,
+		})
+	}
+
+	if utils.HasFileExtension(name, policy.DeniedExtensions) ||
+		(len(policy.AllowedExtensions) > 0 && !utils.HasFileExtension(name, policy.AllowedExtensions)) {
+		add(PolicyRuleExtension, config.PolicyActionBlock, "", strings.ToLower(path.Ext(name)))
+	}
+
+	mimeType := utils.GetMimeType(name)
+	for key, limit := range policy.MaxSizes {
+		if limit > 0 && size > limit && matchesSizeKey(key, name, mimeType) {
+			add(PolicyRuleMaxSize, config.PolicyActionBlock, key, strconv.FormatInt(limit, 10))
+		}
+	}
+
+	if content != nil {
+		data, err := io.ReadAll(io.LimitReader(content, policyScanSize))
+		if err != nil {
+			return nil, err
+		}
+
+		if policy.VerifyMimeType {
+			if detected, ok := verifyMimeType(mimeType, data); !ok {
+				add(PolicyRuleMimeType, config.PolicyActionBlock, "", detected)
+			}
+		}
+
+		// Patterns only apply to text
+		if !bytes.Contains(data, []byte{0}) {
+			text := string(data)
+			ext := strings.ToLower(path.Ext(name))
+			if policy.XSSCheck != "" && (ext == ".html" || ext == ".htm" || ext == ".js") && utils.CheckXSSPatterns(text) {
+				add(PolicyRuleXSS, policy.XSSCheck, "", "")
+			}
+			for _, rule := range policy.ContentRules {
+				if len(rule.Extensions) > 0 && !utils.HasFileExtension(name, rule.Extensions) {
+					continue
+				}
+				re, err := compilePolicyPattern(rule.Pattern)
+				if err == nil && re.MatchString(text) {
+					add(PolicyRuleContent, rule.Action, rule.Name, "")
+				}
+			}
+		}
+	}
+
+	for _, v := range violations {
+		if v.Action == config.PolicyActionBlock {
+			return nil, &ContentPolicyError{Violations: violations}
+		}
+	}
+	return violations, nil
+}
+
+// CheckStoredContentPolicy checks a file already in storage against the
+// content policy of owner as if it were written to relPath, for files that
+// are renamed or copied. Folders are checked file by file.
+func CheckStoredContentPolicy(owner *models.User, fullPath, relPath string) ([]PolicyViolation, error) {
+	info, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir {
+		return checkStoredFile(owner, fullPath, relPath, info.Size)
+	}
+
+	entries, err := storage.Store.List(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	var warnings []PolicyViolation
+	for _, entry := range entries {
+		if entry.IsDir {
+			continue
+		}
+		fileWarnings, err := checkStoredFile(owner, path.Join(fullPath, entry.Path), path.Join(relPath, entry.Path), entry.Size)
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, fileWarnings...)
+	}
+	return warnings, nil
+}
+
+func checkStoredFile(owner *models.User, fullPath, relPath string, size int64) ([]PolicyViolation, error) {
+	f, err := storage.Store.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return CheckContentPolicy(owner, relPath, size, f)
+}
+
+func isPolicyAction(action string) bool {
+	return action == config.PolicyActionWarn || action == config.PolicyActionBlock
+}
+
+func compilePolicyPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := policyPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	policyPatterns.Store(pattern, re)
+	return re, nil
+}
+
+// matchesSizeKey reports whether a max_sizes key applies to a file. Keys are
+// extensions, MIME types or MIME groups like "video/*".
+func matchesSizeKey(key, name, mimeType string) bool {
+	if strings.HasPrefix(key, ".") {
+		return utils.HasFileExtension(name, []string{key})
+	}
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	if group, ok := strings.CutSuffix(key, "/*"); ok {
+		return strings.HasPrefix(mediaType, group+"/")
+	}
+	return strings.EqualFold(key, mediaType)
+}
+
+// verifyMimeType checks the magic bytes of content against the MIME type of
+// its extension and returns the detected type. Text types only need to be
+// free of binary data; media types must not sniff as text or as another
+// kind of media, so markup cannot pass as an image. Other types, and content
+// the sniffer does not recognise, are accepted.
+func verifyMimeType(mimeType string, content []byte) (string, bool) {
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+	expected, _, _ := mime.ParseMediaType(mimeType)
+	if expected == "" || len(content) == 0 {
+		return detected, true
+	}
+
+	if utils.IsTextMediaType(expected) {
+		return detected, !bytes.Contains(content, []byte{0})
+	}
+
+	if !isMediaType(expected) {
+		return detected, true
+	}
+	if strings.HasPrefix(detected, "text/") {
+		return detected, false
+	}
+	if isMediaType(detected) {
+		return detected, mediaFamily(detected) == mediaFamily(expected)
+	}
+	return detected, true
+}
+
+func isMediaType(mediaType string) bool {
+	switch group, _, _ := strings.Cut(mediaType, "/"); group {
+	case "image", "audio", "video", "font":
+		return true
+	}
+	return false
+}
+
+// mediaFamily groups media types whose extensions are commonly mapped to
+// either type, like audio/mp4 and video/mp4 or font/woff and
+// application/font-woff
+func mediaFamily(mediaType string) string {
+	group, _, _ := strings.Cut(mediaType, "/")
+	switch group {
+	case "audio", "video":
+		return "av"
+	case "font":
+		return "application"
+	}
+	return group
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+)
+
+// CreateDeployment freezes the project's working tree into a new numbered
+// deployment and makes it the active one
+func CreateDeployment(project *models.Project, username string, userID uint) (*models.Deployment, error) {
+	db := database.GetDB()
+	projectPath := project.GetStoragePath(username)
+
+	entries, err := storage.Store.List(projectPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project files: %w", err)
+	}
+
+	// Reserve the next deployment number; the unique index on
+	// (project_id, number) rejects concurrent publishes of the same number
+	var last models.Deployment
+	number := 1
+	if err := db.Where("project_id = ?", project.ID).Order("number DESC").First(&last).Error; err == nil {
+		number = last.Number + 1
+	}
+
+	deployment := models.Deployment{
+		ProjectID: project.ID,
+		Number:    number,
+		CreatedBy: userID,
+	}
+	if err := db.Create(&deployment).Error; err != nil {
+		return nil, fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	// Copy every file into the snapshot
+	deploymentPath := deployment.GetStoragePath()
+	for _, entry := range entries {
+		if entry.IsDir {
+			continue
+		}
+		if err := storage.Store.Copy(path.Join(projectPath, entry.Path), path.Join(deploymentPath, entry.Path)); err != nil {
+			DeleteDeployment(&deployment)
+			return nil, fmt.Errorf("failed to copy %s: %w", entry.Path, err)
+		}
+		deployment.FileCount++
+		deployment.TotalSize += entry.Size
+	}
+
+	if err := db.Model(&deployment).Updates(map[string]interface{}{
+		"file_count": deployment.FileCount,
+		"total_size": deployment.TotalSize,
+	}).Error; err != nil {
+		DeleteDeployment(&deployment)
+		return nil, fmt.Errorf("failed to update deployment: %w", err)
+	}
+
+	if err := ActivateDeployment(project, &deployment); err != nil {
+		DeleteDeployment(&deployment)
+		return nil, err
+	}
+
+	pruneDeployments(project.ID, deployment.ID)
+
+	return &deployment, nil
+}
+
+// DeleteDeployment removes a deployment snapshot and its record
+func DeleteDeployment(deployment *models.Deployment) error {
+	if err := storage.Store.Delete(deployment.GetStoragePath()); err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return err
+	}
+	return database.GetDB().Delete(deployment).Error
+}
+
+// CleanupExpiredDeployments removes the deployments older than the
+// configured retention, except the ones projects are serving
+func CleanupExpiredDeployments() error {
+	maxAgeDays := config.GetConfig().Deployments.MaxAgeDays
+	if maxAgeDays <= 0 {
+		return nil
+	}
+
+	db := database.GetDB()
+	active := db.Model(&models.Project{}).Select("active_deployment_id").Where("active_deployment_id IS NOT NULL")
+	cutoff := time.Now().AddDate(0, 0, -maxAgeDays)
+
+	var deployments []models.Deployment
+	if err := db.Where("created_at < ? AND id NOT IN (?)", cutoff, active).Find(&deployments).Error; err != nil {
+		return err
+	}
+	for i := range deployments {
+		if err := DeleteDeployment(&deployments[i]); err != nil {
+			log.Printf("Failed to delete deployment %d: %v", deployments[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// pruneDeployments applies the configured retention policy to a project,
+// always keeping the active deployment
+func pruneDeployments(projectID, activeID uint) {
+	cfg := config.GetConfig()
+
+	var deployments []models.Deployment
+	if err := database.GetDB().Where("project_id = ?", projectID).Order("number DESC").Find(&deployments).Error; err != nil {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -cfg.Deployments.MaxAgeDays)
+	for i := range deployments {
+		if deployments[i].ID == activeID {
+			continue
+		}
+		if (cfg.Deployments.MaxDeployments > 0 && i >= cfg.Deployments.MaxDeployments) ||
+			(cfg.Deployments.MaxAgeDays > 0 && deployments[i].CreatedAt.Before(cutoff)) {
+			if err := DeleteDeployment(&deployments[i]); err != nil {
+				log.Printf("Failed to delete deployment %d: %v", deployments[i].ID, err)
+			}
+		}
+	}
+}
+
+// ActivateDeployment makes a deployment the one served for its project.
+// Switching is a single row update, so visitors see either the old or the
+// new snapshot and never a mix of both.
+func ActivateDeployment(project *models.Project, deployment *models.Deployment) error {
+	if err := database.GetDB().Model(project).Update("active_deployment_id", deployment.ID).Error; err != nil {
+		return fmt.Errorf("failed to activate deployment: %w", err)
+	}
+	return nil
+}
+
+// DeleteProjectDeployments removes all deployment snapshots and records of a project
+func DeleteProjectDeployments(projectID uint) error {
+	snapshotsPath := fmt.Sprintf("%s/%d", models.DeploymentsRoot, projectID)
+	if storage.Exists(storage.Store, snapshotsPath) {
+		if err := storage.Store.Delete(snapshotsPath); err != nil {
+			return err
+		}
+	}
+	return database.GetDB().Where("project_id = ?", projectID).Delete(&models.Deployment{}).Error
+}
@@ -0,0 +1,69 @@
+package services
+
+import "sync"
+
+// Kinds of file changes
+const (
+	FileChangeWrite  = "write"
+	FileChangeMove   = "move"
+	FileChangeDelete = "delete"
+)
+
+// fileWatcherBuffer is how many changes are queued for a watcher before
+// further changes are dropped
+const fileWatcherBuffer = 64
+
+// FileChange is a change to the files of a project. An empty Path means
+// anything in the project may have changed.
+type FileChange struct {
+	Type    string `json:"type"`
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+}
+
+// fileWatchers holds the change queues of everyone watching a project
+var (
+	fileWatchersMu sync.Mutex
+	fileWatchers   = map[uint]map[chan FileChange]struct{}{}
+)
+
+// WatchProjectFiles returns a queue receiving the file changes of a project,
+// and the function that stops watching and closes it
+func WatchProjectFiles(projectID uint) (<-chan FileChange, func()) {
+	ch := make(chan FileChange, fileWatcherBuffer)
+
+	fileWatchersMu.Lock()
+	if fileWatchers[projectID] == nil {
+		fileWatchers[projectID] = map[chan FileChange]struct{}{}
+	}
+	fileWatchers[projectID][ch] = struct{}{}
+	fileWatchersMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			fileWatchersMu.Lock()
+			defer fileWatchersMu.Unlock()
+
+			delete(fileWatchers[projectID], ch)
+			if len(fileWatchers[projectID]) == 0 {
+				delete(fileWatchers, projectID)
+			}
+			close(ch)
+		})
+	}
+}
+
+// PublishFileChange tells the watchers of a project that files changed. It
+// never blocks; watchers that fall behind miss changes.
+func PublishFileChange(projectID uint, change FileChange) {
+	fileWatchersMu.Lock()
+	defer fileWatchersMu.Unlock()
+
+	for ch := range fileWatchers[projectID] {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
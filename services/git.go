@@ -0,0 +1,635 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// DeployTokenPrefix starts every deploy token, so leaked tokens are easy to spot
+const DeployTokenPrefix = "sfdt_"
+
+// gitFetchTimeout bounds how long fetching from a git remote may take
+const gitFetchTimeout = 10 * time.Minute
+
+var (
+	// ErrDeployTokenNotFound is returned for unknown deploy tokens
+	ErrDeployTokenNotFound = errors.New("deploy token not found")
+	// ErrGitRemoteNotSet is returned when syncing a project without a git remote
+	ErrGitRemoteNotSet = errors.New("no git remote configured")
+	// ErrGitBranchNotFound is returned when the remote does not have the project's branch
+	ErrGitBranchNotFound = errors.New("branch not found on git remote")
+	// ErrGitRemoteNotAllowed is returned when the remote's host resolves to an internal address
+	ErrGitRemoteNotAllowed = errors.New("git remote not allowed")
+)
+
+// gitLocks serializes pushes to the same project
+var gitLocks sync.Map
+
+// prereceiveHook rejects pushes the project cannot take: refs other than
+// the deploy branch, deleting the branch, files over the upload size limit,
+// and trees that would exceed the owner's quota. Limits are passed in by
+// ProjectGitEnv and match checkGitTree.
+const prereceiveHook = `#!/bin/sh
+# Installed by StaticForge, changes are overwritten
+zero=0000000000000000000000000000000000000000
+while read old new ref; do
+	if [ "$ref" != "$SF_DEPLOY_REF" ]; then
+		echo "StaticForge: only the deploy branch ${SF_DEPLOY_REF#refs/heads/} can be pushed" >&2
+		exit 1
+	fi
+	if [ "$new" = "$zero" ]; then
+		echo "StaticForge: the deploy branch cannot be deleted" >&2
+		exit 1
+	fi
+	git ls-tree -r -l "$new" | awk -F '\t' \
+		-v max_bytes="${SF_MAX_BYTES:--1}" -v max_files="${SF_MAX_FILES:--1}" -v max_file_size="${SF_MAX_FILE_SIZE:--1}" '
+		{
+			split($1, info, " ")
+			if (info[2] != "blob" || info[1] == "120000") next
+			files++
+			bytes += info[4]
+			if (max_file_size >= 0 && info[4] > max_file_size) {
+				print "StaticForge: " $2 " exceeds the maximum file size" > "/dev/stderr"
+				failed = 1
+			}
+		}
+		END {
+			if (max_bytes >= 0 && bytes > max_bytes) {
+				print "StaticForge: the pushed files exceed the storage quota" > "/dev/stderr"
+				failed = 1
+			}
+			if (max_files >= 0 && files > max_files) {
+				print "StaticForge: the pushed files exceed the file count quota" > "/dev/stderr"
+				failed = 1
+			}
+			exit failed
+		}' || exit 1
+done
+`
+
+// LockProjectGit blocks until no other push to the project is running and
+// returns the function releasing the lock
+func LockProjectGit(projectID uint) func() {
+	value, _ := gitLocks.LoadOrStore(projectID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// GetProjectRepoPath returns the local path of a project's bare repository
+func GetProjectRepoPath(projectID uint) string {
+	return filepath.Join(config.GetConfig().Git.RepoDir, fmt.Sprintf("%d.git", projectID))
+}
+
+// GetProjectGitRef returns the ref whose commits are synced to the working tree
+func GetProjectGitRef(project *models.Project) string {
+	branch := project.GitBranch
+	if branch == "" {
+		branch = "main"
+	}
+	return "refs/heads/" + branch
+}
+
+// InitProjectRepo creates the bare repository of a project if needed and
+// keeps its HEAD and hooks in line with the project settings
+func InitProjectRepo(project *models.Project) (string, error) {
+	repoPath := GetProjectRepoPath(project.ID)
+
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(repoPath), 0755); err != nil {
+			return "", err
+		}
+		if _, err := runGit("", "init", "--bare", "--quiet", repoPath); err != nil {
+			return "", err
+		}
+	}
+
+	// Clones check out the deploy branch
+	if _, err := runGit(repoPath, "symbolic-ref", "HEAD", GetProjectGitRef(project)); err != nil {
+		return "", err
+	}
+
+	hookPath := filepath.Join(repoPath, "hooks", "pre-receive")
+	if err := os.WriteFile(hookPath, []byte(prereceiveHook), 0755); err != nil {
+		return "", fmt.Errorf("failed to install pre-receive hook: %w", err)
+	}
+	return repoPath, nil
+}
+
+// gitLimits bounds the tree of a commit synced to a project. Negative
+// limits are not enforced.
+type gitLimits struct {
+	maxBytes    int64
+	maxFiles    int64
+	maxFileSize int64
+}
+
+// getProjectGitLimits returns the limits for commits synced to a project.
+// The quota left for the project is the owner's limit minus what their
+// other projects use, but never less than the project already uses, so
+// commits that do not grow it are always accepted.
+func getProjectGitLimits(project *models.Project) (gitLimits, error) {
+	limits := gitLimits{maxBytes: -1, maxFiles: -1, maxFileSize: config.GetConfig().Upload.MaxSize}
+
+	limit := GetQuotaLimit(&project.User)
+	if limit.MaxBytes <= 0 && limit.MaxFiles <= 0 {
+		return limits, nil
+	}
+
+	// Pushes are rare enough to always count the storage afresh
+	usage, err := refreshUserUsage(&project.User)
+	if err != nil {
+		return limits, err
+	}
+
+	current, err := storedUsage(project.GetStoragePath(project.User.Username))
+	if err != nil {
+		return limits, err
+	}
+
+	if limit.MaxBytes > 0 {
+		limits.maxBytes = max(limit.MaxBytes-(usage.Bytes-current.Bytes), current.Bytes)
+	}
+	if limit.MaxFiles > 0 {
+		limits.maxFiles = max(limit.MaxFiles-(usage.Files-current.Files), current.Files)
+	}
+	return limits, nil
+}
+
+// ProjectGitEnv returns the environment passed to the pre-receive hook for
+// a push to a project
+func ProjectGitEnv(project *models.Project) ([]string, error) {
+	limits, err := getProjectGitLimits(project)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		"SF_DEPLOY_REF=" + GetProjectGitRef(project),
+		fmt.Sprintf("SF_MAX_BYTES=%d", limits.maxBytes),
+		fmt.Sprintf("SF_MAX_FILES=%d", limits.maxFiles),
+		fmt.Sprintf("SF_MAX_FILE_SIZE=%d", limits.maxFileSize),
+	}, nil
+}
+
+// checkGitTree applies the checks of the pre-receive hook to a commit that
+// was fetched rather than pushed
+func checkGitTree(repoPath, commit string, limits gitLimits) error {
+	out, err := runGit(repoPath, "ls-tree", "-r", "-l", "-z", commit)
+	if err != nil {
+		return err
+	}
+
+	var total Usage
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+		info, name, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 4 || fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return err
+		}
+		if limits.maxFileSize >= 0 && size > limits.maxFileSize {
+			return fmt.Errorf("%s exceeds the maximum file size", name)
+		}
+		total.Bytes += size
+		total.Files++
+	}
+
+	if limits.maxBytes >= 0 && total.Bytes > limits.maxBytes {
+		return ErrQuotaExceeded
+	}
+	if limits.maxFiles >= 0 && total.Files > limits.maxFiles {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// RunGitService runs git upload-pack or receive-pack in stateless RPC mode,
+// as used by the smart HTTP protocol. With advertise set only the ref
+// advertisement is written.
+func RunGitService(w io.Writer, r io.Reader, repoPath, service string, advertise bool, env []string) error {
+	args := []string{service, "--stateless-rpc"}
+	if advertise {
+		args = append(args, "--advertise-refs")
+	}
+	args = append(args, repoPath)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", service, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ResolveGitRef returns the commit a ref points to, or "" if it does not exist
+func ResolveGitRef(repoPath, ref string) (string, error) {
+	out, err := runGit(repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// DeployGitCommit replaces the working tree of a project with the files of
+// a commit and publishes the project if auto publishing is enabled
+func DeployGitCommit(project *models.Project, repoPath, commit string, userID uint) error {
+	if err := SyncProjectFromGit(project, repoPath, commit, userID); err != nil {
+		return err
+	}
+	if !project.GitAutoPublish {
+		return nil
+	}
+
+	if _, err := CreateDeployment(project, project.User.Username, userID); err != nil {
+		return err
+	}
+	return database.GetDB().Model(project).Update("is_published", true).Error
+}
+
+// SyncProjectFromRemote fetches the project's git branch from its remote and
+// deploys the fetched commit if it changed. The outcome is recorded in the
+// project's git sync status.
+func SyncProjectFromRemote(project *models.Project, userID uint) error {
+	unlock := LockProjectGit(project.ID)
+	defer unlock()
+
+	db := database.GetDB()
+	if err := db.Model(project).Update("git_sync_status", models.GitSyncRunning).Error; err != nil {
+		return err
+	}
+
+	err := syncProjectFromRemote(project, userID)
+	if dbErr := recordGitSync(project, err); dbErr != nil && err == nil {
+		return dbErr
+	}
+	return err
+}
+
+// DeployPushedCommit deploys a commit pushed to the project repository. The
+// outcome is recorded in the project's git sync status, as for remote syncs.
+func DeployPushedCommit(project *models.Project, repoPath, commit string, userID uint) error {
+	err := DeployGitCommit(project, repoPath, commit, userID)
+	if dbErr := recordGitSync(project, err); dbErr != nil && err == nil {
+		return dbErr
+	}
+	return err
+}
+
+// recordGitSync stores the outcome of a sync in the project's git sync status
+func recordGitSync(project *models.Project, err error) error {
+	updates := map[string]interface{}{
+		"git_sync_status": models.GitSyncSuccess,
+		"git_sync_error":  "",
+		"git_synced_at":   time.Now(),
+	}
+	if err != nil {
+		updates["git_sync_status"] = models.GitSyncFailed
+		updates["git_sync_error"] = err.Error()
+	}
+	return database.GetDB().Model(project).Updates(updates).Error
+}
+
+// syncProjectFromRemote does the work of SyncProjectFromRemote. The branch is
+// fetched shallowly into a scratch repository and checked against the same
+// limits as a push; only a commit that passed and was deployed is copied
+// into the project repository.
+func syncProjectFromRemote(project *models.Project, userID uint) error {
+	if project.GitRemoteURL == "" {
+		return ErrGitRemoteNotSet
+	}
+
+	// DNS may have changed since the remote was set, so check it again
+	gitConfig := config.GetConfig().Git
+	if !utils.ValidateGitRemoteURL(project.GitRemoteURL, gitConfig.AllowFileRemotes, gitConfig.AllowPrivateRemotes) {
+		return ErrGitRemoteNotAllowed
+	}
+
+	repoPath, err := InitProjectRepo(project)
+	if err != nil {
+		return err
+	}
+
+	// Remotes are user supplied, so restrict transports, do not follow redirects
+	// and never prompt for credentials
+	protocols := "http:https:git"
+	if gitConfig.AllowFileRemotes {
+		protocols += ":file"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gitFetchTimeout)
+	defer cancel()
+
+	scratchPath, err := os.MkdirTemp(filepath.Dir(repoPath), fmt.Sprintf("%d.fetch-", project.ID))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratchPath)
+	if _, err := runGit("", "init", "--bare", "--quiet", scratchPath); err != nil {
+		return err
+	}
+
+	ref := GetProjectGitRef(project)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "-c", "http.followRedirects=false", "fetch", "--quiet", "--no-tags", "--depth=1", "--", project.GitRemoteURL, ref+":"+ref)
+	cmd.Dir = scratchPath
+	cmd.Env = append(os.Environ(), "GIT_ALLOW_PROTOCOL="+protocols, "GIT_TERMINAL_PROMPT=0")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "couldn't find remote ref") {
+			return ErrGitBranchNotFound
+		}
+		return fmt.Errorf("git fetch: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	commit, err := ResolveGitRef(scratchPath, ref)
+	if err != nil {
+		return err
+	}
+	if commit == "" {
+		return ErrGitBranchNotFound
+	}
+	if commit == project.GitCommit {
+		return nil
+	}
+
+	limits, err := getProjectGitLimits(project)
+	if err != nil {
+		return err
+	}
+	if err := checkGitTree(scratchPath, commit, limits); err != nil {
+		return err
+	}
+
+	if err := DeployGitCommit(project, scratchPath, commit, userID); err != nil {
+		return err
+	}
+
+	// Clones from the project repository see what is deployed
+	_, err = runGit(repoPath, "fetch", "--quiet", "--no-tags", "--depth=1", "--update-head-ok", "--", scratchPath, "+"+ref+":"+ref)
+	return err
+}
+
+// GenerateGitHookToken returns a random secret for a deploy hook URL
+func GenerateGitHookToken() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// SyncProjectFromGit makes the working tree of a project match a commit.
+// Changed files are written and files missing from the commit are moved to
+// the trash, except the root index.html. All changes are rolled back if any
+// of them fails or breaks the owner's content policy.
+func SyncProjectFromGit(project *models.Project, repoPath, commit string, userID uint) error {
+	archive, err := os.CreateTemp("", "staticforge-git-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	if _, err := runGit(repoPath, "archive", "--format=tar.gz", "--output", archive.Name(), commit+"^{tree}"); err != nil {
+		return err
+	}
+	info, err := archive.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Collect the paths of the commit first, so removals can be planned
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	err = utils.WalkArchive(archive, info.Size(), utils.ArchiveTarGz, func(entry utils.ArchiveEntry, content io.Reader) error {
+		name, ok := gitArchivePath(entry.Name)
+		if !ok {
+			return nil
+		}
+		if entry.IsDir {
+			dirs[name] = true
+		} else if entry.IsRegular {
+			files[name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %w", commit, err)
+	}
+
+	projectPath := project.GetStoragePath(project.User.Username)
+	entries, err := storage.Store.List(projectPath)
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	tx, err := BeginFileTransaction()
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Failed to roll back git sync: %v", rbErr)
+		}
+		return err
+	}
+
+	// History and trash records are only updated once the sync has been applied
+	var afterCommit []func() error
+	existing := make(map[string]bool)
+	removed := ""
+	for _, entry := range entries {
+		if removed != "" && strings.HasPrefix(entry.Path, removed+"/") {
+			continue
+		}
+		keep := files[entry.Path]
+		if entry.IsDir {
+			keep = dirs[entry.Path]
+		}
+		if keep || entry.Path == "index.html" {
+			if !entry.IsDir {
+				existing[entry.Path] = true
+			}
+			continue
+		}
+
+		item, err := NewTrashItem(project.ID, projectPath, entry.Path, userID)
+		if err != nil {
+			return fail(err)
+		}
+		if err := tx.Move(path.Join(projectPath, entry.Path), item.GetStoragePath()); err != nil {
+			return fail(err)
+		}
+		afterCommit = append(afterCommit, func() error {
+			return SaveTrashItem(item)
+		})
+		removed = entry.Path
+	}
+
+	err = utils.WalkArchive(archive, info.Size(), utils.ArchiveTarGz, func(entry utils.ArchiveEntry, content io.Reader) error {
+		name, ok := gitArchivePath(entry.Name)
+		if !ok || !entry.IsRegular {
+			return nil
+		}
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+
+		fullPath := path.Join(projectPath, name)
+		if existing[name] {
+			previous, err := storage.Store.Read(fullPath)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(previous, data) {
+				return nil
+			}
+			// Keep the content from before the first tracked change as a baseline revision
+			if utils.IsTextFile(name, previous) && !HasRevisions(project.ID, name) {
+				RecordRevision(project.ID, name, previous, project.UserID)
+			}
+		}
+
+		if _, err := CheckContentPolicy(&project.User, name, int64(len(data)), bytes.NewReader(data)); err != nil {
+			return err
+		}
+		if err := tx.Write(fullPath, data); err != nil {
+			return err
+		}
+		if utils.IsTextFile(name, data) {
+			afterCommit = append(afterCommit, func() error {
+				_, err := RecordRevision(project.ID, name, data, userID)
+				return err
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to sync commit %s: %w", commit, err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to clean up git sync backups: %v", err)
+	}
+	PublishFileChange(project.ID, FileChange{Type: FileChangeWrite})
+	for _, fn := range afterCommit {
+		if err := fn(); err != nil {
+			log.Printf("Failed to update file records after git sync: %v", err)
+		}
+	}
+
+	return database.GetDB().Model(project).Update("git_commit", commit).Error
+}
+
+// CreateDeployToken creates a deploy token for a project and returns it
+// together with the plain token, which is not stored
+func CreateDeployToken(projectID uint, name string, userID uint) (*models.DeployToken, string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	token := DeployTokenPrefix + hex.EncodeToString(secret)
+
+	deployToken := models.DeployToken{
+		ProjectID: projectID,
+		Name:      name,
+		TokenHash: hashDeployToken(token),
+		CreatedBy: userID,
+	}
+	if err := database.GetDB().Create(&deployToken).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create deploy token: %w", err)
+	}
+	return &deployToken, token, nil
+}
+
+// FindDeployToken looks up a deploy token and records its use
+func FindDeployToken(token string) (*models.DeployToken, error) {
+	if !strings.HasPrefix(token, DeployTokenPrefix) {
+		return nil, ErrDeployTokenNotFound
+	}
+
+	var deployToken models.DeployToken
+	if err := database.GetDB().Where("token_hash = ?", hashDeployToken(token)).First(&deployToken).Error; err != nil {
+		return nil, ErrDeployTokenNotFound
+	}
+
+	now := time.Now()
+	database.GetDB().Model(&deployToken).Update("last_used_at", now)
+	deployToken.LastUsedAt = &now
+	return &deployToken, nil
+}
+
+// DeleteProjectGit removes the repository and deploy tokens of a project
+func DeleteProjectGit(projectID uint) error {
+	if err := os.RemoveAll(GetProjectRepoPath(projectID)); err != nil {
+		return err
+	}
+	return database.GetDB().Where("project_id = ?", projectID).Delete(&models.DeployToken{}).Error
+}
+
+// hashDeployToken returns the hex encoded SHA-256 hash stored for a token
+func hashDeployToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// gitArchivePath cleans the name of a git archive entry, rejecting names
+// outside the archive root
+func gitArchivePath(name string) (string, bool) {
+	name = path.Clean(strings.TrimSuffix(name, "/"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// runGit runs a git command and returns its output. dir is the working
+// directory, or "" for the current one.
+func runGit(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+	"gorm.io/gorm"
+)
+
+// HashContent returns the hex encoded SHA-256 hash of content
+func HashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// HasRevisions reports whether any revision was recorded for a project file
+func HasRevisions(projectID uint, filePath string) bool {
+	var count int64
+	database.GetDB().Model(&models.FileRevision{}).
+		Where("project_id = ? AND path = ?", projectID, filePath).
+		Count(&count)
+	return count > 0
+}
+
+// RecordRevision stores content as the newest revision of a project file.
+// Nothing is recorded when the content matches the latest revision.
+func RecordRevision(projectID uint, filePath string, content []byte, userID uint) (*models.FileRevision, error) {
+	db := database.GetDB()
+	hash := HashContent(content)
+
+	var latest models.FileRevision
+	if err := db.Where("project_id = ? AND path = ?", projectID, filePath).Order("id DESC").First(&latest).Error; err == nil && latest.Hash == hash {
+		return &latest, nil
+	}
+
+	revision := models.FileRevision{
+		ProjectID: projectID,
+		Path:      filePath,
+		Hash:      hash,
+		Size:      int64(len(content)),
+		UserID:    userID,
+	}
+
+	// Store the content once per project and hash
+	blobPath := revision.GetStoragePath()
+	if !storage.Exists(storage.Store, blobPath) {
+		if err := storage.WriteFile(storage.Store, blobPath, content); err != nil {
+			return nil, fmt.Errorf("failed to store revision content: %w", err)
+		}
+	}
+
+	if err := db.Create(&revision).Error; err != nil {
+		return nil, fmt.Errorf("failed to create revision: %w", err)
+	}
+
+	pruneRevisions(projectID, filePath)
+	return &revision, nil
+}
+
+// RecordDeletedRevisions records the current content of a file, or of every
+// file below a folder, so that it can still be restored after deletion
+func RecordDeletedRevisions(projectID uint, projectPath, relPath string, userID uint) error {
+	fullPath := path.Join(projectPath, relPath)
+	info, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+
+	files := []string{relPath}
+	if info.IsDir {
+		entries, err := storage.Store.List(fullPath)
+		if err != nil {
+			return err
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			if !entry.IsDir {
+				files = append(files, path.Join(relPath, entry.Path))
+			}
+		}
+	}
+
+	for _, file := range files {
+		content, err := storage.Store.Read(path.Join(projectPath, file))
+		if err != nil {
+			return err
+		}
+		if _, err := RecordRevision(projectID, file, content, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRevisionContent returns the stored content of a revision
+func GetRevisionContent(revision *models.FileRevision) ([]byte, error) {
+	return storage.Store.Read(revision.GetStoragePath())
+}
+
+// MoveRevisions re-keys the history of a file, or of every file below a
+// folder, after it has been renamed or moved
+func MoveRevisions(projectID uint, oldPath, newPath string) error {
+	return database.GetDB().Model(&models.FileRevision{}).
+		Where("project_id = ? AND (path = ? OR path LIKE ?)", projectID, oldPath, escapeLike(oldPath)+"/%").
+		Update("path", gorm.Expr("CONCAT(?, SUBSTRING(path, CHAR_LENGTH(?) + 1))", newPath, oldPath)).Error
+}
+
+// DeleteProjectRevisions removes all revisions and stored contents of a project
+func DeleteProjectRevisions(projectID uint) error {
+	historyPath := fmt.Sprintf("%s/%d", models.HistoryRoot, projectID)
+	if storage.Exists(storage.Store, historyPath) {
+		if err := storage.Store.Delete(historyPath); err != nil {
+			return err
+		}
+	}
+	return database.GetDB().Where("project_id = ?", projectID).Delete(&models.FileRevision{}).Error
+}
+
+// pruneRevisions applies the configured retention policy to a project file,
+// always keeping its newest revision
+func pruneRevisions(projectID uint, filePath string) {
+	db := database.GetDB()
+	cfg := config.GetConfig()
+
+	var revisions []models.FileRevision
+	if err := db.Where("project_id = ? AND path = ?", projectID, filePath).Order("id DESC").Find(&revisions).Error; err != nil {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -cfg.History.MaxAgeDays)
+	var expired []models.FileRevision
+	for i, revision := range revisions {
+		if i == 0 {
+			continue
+		}
+		if (cfg.History.MaxRevisions > 0 && i >= cfg.History.MaxRevisions) ||
+			(cfg.History.MaxAgeDays > 0 && revision.CreatedAt.Before(cutoff)) {
+			expired = append(expired, revision)
+		}
+	}
+
+	for i := range expired {
+		deleteRevision(&expired[i])
+	}
+}
+
+// CleanupExpiredRevisions removes the revisions older than the configured
+// retention, including those of files that are not saved again or were
+// deleted. The newest revision of a file that still exists is kept.
+func CleanupExpiredRevisions() error {
+	maxAgeDays := config.GetConfig().History.MaxAgeDays
+	if maxAgeDays <= 0 {
+		return nil
+	}
+
+	db := database.GetDB()
+	cutoff := time.Now().AddDate(0, 0, -maxAgeDays)
+
+	var revisions []models.FileRevision
+	if err := db.Preload("Project.User").Where("created_at < ?", cutoff).Order("id DESC").Find(&revisions).Error; err != nil {
+		return err
+	}
+	for i := range revisions {
+		revision := &revisions[i]
+
+		var newer int64
+		db.Model(&models.FileRevision{}).Where("project_id = ? AND path = ? AND id > ?", revision.ProjectID, revision.Path, revision.ID).Count(&newer)
+		if newer == 0 && revision.Project.ID != 0 {
+			projectPath := revision.Project.GetStoragePath(revision.Project.User.Username)
+			if storage.Exists(storage.Store, path.Join(projectPath, revision.Path)) {
+				continue
+			}
+		}
+		deleteRevision(revision)
+	}
+	return nil
+}
+
+// deleteRevision removes a revision, and its content once no revision of the
+// project references it
+func deleteRevision(revision *models.FileRevision) {
+	db := database.GetDB()
+	if err := db.Delete(revision).Error; err != nil {
+		return
+	}
+
+	var count int64
+	db.Model(&models.FileRevision{}).Where("project_id = ? AND hash = ?", revision.ProjectID, revision.Hash).Count(&count)
+	if count == 0 {
+		storage.Store.Delete(revision.GetStoragePath())
+	}
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path"
+
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// maxClientRevisionSize is the largest file saved by a WebDAV or SFTP client
+// that is added to the file history
+const maxClientRevisionSize = 2 << 20
+
+// SaveProjectFile replaces a project file with content staged in a local
+// file by a WebDAV or SFTP client. The change is checked against the owner's
+// quota and content policy, and text files are kept in the file history.
+// project.User must be loaded.
+func SaveProjectFile(project *models.Project, projectPath, relPath string, staged *os.File, size int64, userID uint) error {
+	fullPath := path.Join(projectPath, relPath)
+
+	existing, err := storage.Store.Stat(fullPath)
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return err
+	}
+	addBytes, addFiles := size, int64(1)
+	if existing != nil {
+		addBytes, addFiles = size-existing.Size, 0
+	}
+	if err := CheckQuota(&project.User, addBytes, addFiles); err != nil {
+		return err
+	}
+
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := CheckContentPolicy(&project.User, relPath, size, staged); err != nil {
+		return err
+	}
+
+	var content []byte
+	if size <= maxClientRevisionSize {
+		data, err := os.ReadFile(staged.Name())
+		if err != nil {
+			return err
+		}
+		if utils.IsTextFile(relPath, data) {
+			content = data
+		}
+	}
+
+	// Keep the content from before the first tracked save as a baseline revision
+	if content != nil && existing != nil && !HasRevisions(project.ID, relPath) {
+		if previous, err := storage.Store.Read(fullPath); err == nil {
+			RecordRevision(project.ID, relPath, previous, project.UserID)
+		}
+	}
+
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := storage.Store.Write(fullPath, staged, size); err != nil {
+		return err
+	}
+	PublishFileChange(project.ID, FileChange{Type: FileChangeWrite, Path: relPath})
+
+	if content != nil {
+		if _, err := RecordRevision(project.ID, relPath, content, userID); err != nil {
+			log.Printf("Failed to record revision of %s: %v", relPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+)
+
+// ErrQuotaExceeded is returned when a write would exceed the owner's quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// Usage is the storage used by all projects of a user. Bytes include
+// everything kept for the projects: working trees, deployment snapshots,
+// file history, trash and git repositories. Files counts the files of the
+// working trees.
+type Usage struct {
+	Bytes int64
+	Files int64
+}
+
+// usageCacheTTL is how long a computed usage is reused before the storage
+// of the user's projects is walked again
+const usageCacheTTL = 5 * time.Minute
+
+type cachedUsage struct {
+	usage   Usage
+	expires time.Time
+}
+
+var (
+	usageCacheMu sync.Mutex
+	usageCache   = map[uint]cachedUsage{}
+)
+
+// GetQuotaLimit returns the effective quota of a user: the default for the
+// user type, with any per-user override applied
+func GetQuotaLimit(user *models.User) config.QuotaLimit {
+	cfg := config.GetConfig()
+
+	var limit config.QuotaLimit
+	switch {
+	case user.IsAdmin():
+		limit = cfg.Quota.Admin
+	case user.IsVerified():
+		limit = cfg.Quota.Verified
+	default:
+		limit = cfg.Quota.Normal
+	}
+
+	if user.QuotaBytes != nil {
+		limit.MaxBytes = *user.QuotaBytes
+	}
+	if user.QuotaFiles != nil {
+		limit.MaxFiles = *user.QuotaFiles
+	}
+	return limit
+}
+
+// GetUserUsage returns the storage used by a user. The result is cached
+// for a few minutes, with writes accepted by CheckQuota added to it.
+func GetUserUsage(user *models.User) (Usage, error) {
+	usageCacheMu.Lock()
+	cached, ok := usageCache[user.ID]
+	usageCacheMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.usage, nil
+	}
+	return refreshUserUsage(user)
+}
+
+// refreshUserUsage walks the storage of all projects of a user and caches
+// the result
+func refreshUserUsage(user *models.User) (Usage, error) {
+	var usage Usage
+
+	var projects []models.Project
+	if err := database.GetDB().Where("user_id = ?", user.ID).Find(&projects).Error; err != nil {
+		return usage, err
+	}
+
+	for _, project := range projects {
+		projectUsage, err := getProjectUsage(&project, user.Username)
+		if err != nil {
+			return usage, err
+		}
+		usage.Bytes += projectUsage.Bytes
+		usage.Files += projectUsage.Files
+	}
+
+	usageCacheMu.Lock()
+	usageCache[user.ID] = cachedUsage{usage: usage, expires: time.Now().Add(usageCacheTTL)}
+	usageCacheMu.Unlock()
+	return usage, nil
+}
+
+// getProjectUsage returns the storage used by a project owned by username
+func getProjectUsage(project *models.Project, username string) (Usage, error) {
+	usage, err := storedUsage(project.GetStoragePath(username))
+	if err != nil {
+		return usage, err
+	}
+
+	for _, root := range []string{models.DeploymentsRoot, models.HistoryRoot, models.TrashRoot} {
+		stored, err := storedUsage(fmt.Sprintf("%s/%d", root, project.ID))
+		if err != nil {
+			return usage, err
+		}
+		usage.Bytes += stored.Bytes
+	}
+
+	repoBytes, err := localDirSize(GetProjectRepoPath(project.ID))
+	if err != nil {
+		return usage, err
+	}
+	usage.Bytes += repoBytes
+	return usage, nil
+}
+
+// addCachedUsage adds a write to the cached usage of a user, if any
+func addCachedUsage(userID uint, addBytes, addFiles int64) {
+	usageCacheMu.Lock()
+	defer usageCacheMu.Unlock()
+	if cached, ok := usageCache[userID]; ok {
+		cached.usage.Bytes += addBytes
+		cached.usage.Files += addFiles
+		usageCache[userID] = cached
+	}
+}
+
+// storedUsage adds up the files below a storage folder
+func storedUsage(name string) (Usage, error) {
+	var usage Usage
+	entries, err := storage.Store.List(name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return usage, nil
+		}
+		return usage, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir {
+			usage.Bytes += entry.Size
+			usage.Files++
+		}
+	}
+	return usage, nil
+}
+
+// localDirSize adds up the files below a local directory, such as a git
+// repository
+func localDirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	return size, err
+}
+
+// CheckQuota returns ErrQuotaExceeded if adding addBytes and addFiles to the
+// user's current usage would exceed their quota. Negative values are allowed
+// for writes that replace existing content.
+func CheckQuota(user *models.User, addBytes, addFiles int64) error {
+	return checkQuota(user, 0, addBytes, addFiles)
+}
+
+// checkQuota is CheckQuota with reserved bytes counted as used on top of
+// the stored usage
+func checkQuota(user *models.User, reserved, addBytes, addFiles int64) error {
+	limit := GetQuotaLimit(user)
+	if limit.MaxBytes <= 0 && limit.MaxFiles <= 0 {
+		return nil
+	}
+
+	// Writes that do not grow usage are always allowed, so users over
+	// their quota can still edit and shrink files
+	if addBytes <= 0 && addFiles <= 0 {
+		return nil
+	}
+
+	usage, err := GetUserUsage(user)
+	if err != nil {
+		return err
+	}
+	usage.Bytes += reserved
+
+	// The cached usage only grows between walks, so a rejection is
+	// confirmed against the storage in case files were deleted since
+	if exceedsQuota(limit, usage, addBytes, addFiles) {
+		if usage, err = refreshUserUsage(user); err != nil {
+			return err
+		}
+		usage.Bytes += reserved
+		if exceedsQuota(limit, usage, addBytes, addFiles) {
+			return ErrQuotaExceeded
+		}
+	}
+
+	addCachedUsage(user.ID, addBytes, addFiles)
+	return nil
+}
+
+// exceedsQuota reports whether adding addBytes and addFiles to usage goes
+// over limit
+func exceedsQuota(limit config.QuotaLimit, usage Usage, addBytes, addFiles int64) bool {
+	if limit.MaxBytes > 0 && addBytes > 0 && usage.Bytes+addBytes > limit.MaxBytes {
+		return true
+	}
+	return limit.MaxFiles > 0 && addFiles > 0 && usage.Files+addFiles > limit.MaxFiles
+}
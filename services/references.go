@@ -0,0 +1,308 @@
+package services
+
+import (
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// maxReferenceFileSize is the largest file whose references are updated
+const maxReferenceFileSize = 2 << 20
+
+// Patterns of references in HTML, CSS and JS. The reference is in the first
+// submatch that matched.
+var (
+	htmlAttrRefRe    = regexp.MustCompile(`(?i)\s(?:src|href|poster|data|action|formaction)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'<>` + "`" + `]+))`)
+	htmlSrcsetRe     = regexp.MustCompile(`(?i)\s(?:srcset|imagesrcset)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	srcsetURLRe      = regexp.MustCompile(`(?:^|,)\s*([^\s,]+)`)
+	cssURLRefRe      = regexp.MustCompile(`(?i)\burl\(\s*(?:"([^"]*)"|'([^']*)'|([^"'\s)]+))\s*\)`)
+	cssImportRefRe   = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+	jsFromRefRe      = regexp.MustCompile(`\b(?:import|export)\b[^'";]*?\bfrom\s*(?:"([^"]*)"|'([^']*)')`)
+	jsImportRefRe    = regexp.MustCompile(`\bimport\s*(?:"([^"]*)"|'([^']*)')`)
+	jsDynImportRefRe = regexp.MustCompile(`\bimport\s*\(\s*(?:"([^"]*)"|'([^']*)')\s*\)`)
+	urlSchemeRe      = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.\-]*:`)
+)
+
+// ReferenceUpdate is the new content of a file whose references to a moved
+// file or folder were rewritten. Path is the location of the file after the
+// move.
+type ReferenceUpdate struct {
+	Path     string
+	Previous []byte
+	Content  []byte
+}
+
+// referenceSpan is the position of a reference in a file
+type referenceSpan struct {
+	start, end int
+	module     bool // JS module specifier, which must start with ./, ../ or /
+}
+
+// PlanReferenceUpdates finds the HTML, CSS and JS files of a project that
+// reference oldPath, a file or folder about to be moved to newPath, and
+// returns their rewritten contents. Relative and root-relative references
+// are updated, as are the relative references of the moved files
+// themselves. It must be called before the move; nothing is written.
+func PlanReferenceUpdates(owner *models.User, projectPath, oldPath, newPath string) ([]ReferenceUpdate, error) {
+	info, err := storage.Store.Stat(path.Join(projectPath, oldPath))
+	if err != nil {
+		return nil, err
+	}
+	moved := func(p string) string {
+		if p == oldPath {
+			return newPath
+		}
+		if info.IsDir && strings.HasPrefix(p, oldPath+"/") {
+			return newPath + strings.TrimPrefix(p, oldPath)
+		}
+		return p
+	}
+
+	entries, err := storage.Store.List(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []ReferenceUpdate
+	for _, entry := range entries {
+		if entry.IsDir || entry.Size > maxReferenceFileSize {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(entry.Path))
+		if ext != ".html" && ext != ".htm" && ext != ".css" && ext != ".js" && ext != ".mjs" {
+			continue
+		}
+
+		// Files replaced by the move are left alone
+		filePath := moved(entry.Path)
+		if filePath == entry.Path && (filePath == newPath || strings.HasPrefix(filePath, newPath+"/")) {
+			continue
+		}
+
+		content, err := storage.Store.Read(path.Join(projectPath, entry.Path))
+		if err != nil {
+			return nil, err
+		}
+		if !utils.IsUTF8Text(content) {
+			continue
+		}
+
+		rewritten, changed := rewriteReferences(string(content), ext, path.Dir(entry.Path), path.Dir(filePath), moved)
+		if !changed {
+			continue
+		}
+		if _, err := CheckContentPolicy(owner, filePath, int64(len(rewritten)), strings.NewReader(rewritten)); err != nil {
+			return nil, err
+		}
+		updates = append(updates, ReferenceUpdate{
+			Path:     filePath,
+			Previous: content,
+			Content:  []byte(rewritten),
+		})
+	}
+	return updates, nil
+}
+
+// ApplyReferenceUpdates writes the files planned by PlanReferenceUpdates
+// once the move is done. The files are written as a unit and kept in the
+// file history.
+func ApplyReferenceUpdates(project *models.Project, projectPath string, updates []ReferenceUpdate, userID uint) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	tx, err := BeginFileTransaction()
+	if err != nil {
+		return err
+	}
+	for _, update := range updates {
+		// Keep the content from before the first tracked save as a baseline revision
+		if !HasRevisions(project.ID, update.Path) {
+			RecordRevision(project.ID, update.Path, update.Previous, project.UserID)
+		}
+
+		if err := tx.Write(path.Join(projectPath, update.Path), update.Content); err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("Failed to roll back reference updates: %v", rbErr)
+			}
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to clean up reference update backups: %v", err)
+	}
+
+	for _, update := range updates {
+		PublishFileChange(project.ID, FileChange{Type: FileChangeWrite, Path: update.Path})
+		if _, err := RecordRevision(project.ID, update.Path, update.Content, userID); err != nil {
+			log.Printf("Failed to record revision of %s: %v", update.Path, err)
+		}
+	}
+	return nil
+}
+
+// rewriteReferences rewrites the references of a file that was in oldDir
+// and will be in newDir, given where every project path ends up
+func rewriteReferences(content, ext, oldDir, newDir string, moved func(string) string) (string, bool) {
+	spans := findReferences(content, ext)
+
+	var b strings.Builder
+	last, changed := 0, false
+	for _, span := range spans {
+		ref := content[span.start:span.end]
+		newRef, ok := rewriteReference(ref, span.module, oldDir, newDir, moved)
+		if !ok || newRef == ref {
+			continue
+		}
+		b.WriteString(content[last:span.start])
+		b.WriteString(newRef)
+		last, changed = span.end, true
+	}
+	if !changed {
+		return content, false
+	}
+	b.WriteString(content[last:])
+	return b.String(), true
+}
+
+// findReferences returns the positions of the references in a file, sorted
+// and without overlaps
+func findReferences(content, ext string) []referenceSpan {
+	var spans []referenceSpan
+	add := func(re *regexp.Regexp, module bool) {
+		for _, m := range re.FindAllStringSubmatchIndex(content, -1) {
+			for i := 2; i < len(m); i += 2 {
+				if m[i] >= 0 {
+					spans = append(spans, referenceSpan{start: m[i], end: m[i+1], module: module})
+					break
+				}
+			}
+		}
+	}
+
+	isHTML := ext == ".html" || ext == ".htm"
+	if isHTML {
+		add(htmlAttrRefRe, false)
+		for _, m := range htmlSrcsetRe.FindAllStringSubmatchIndex(content, -1) {
+			start, end := m[2], m[3]
+			if start < 0 {
+				start, end = m[4], m[5]
+			}
+			for _, u := range srcsetURLRe.FindAllStringSubmatchIndex(content[start:end], -1) {
+				spans = append(spans, referenceSpan{start: start + u[2], end: start + u[3]})
+			}
+		}
+	}
+	// Inline styles and scripts of HTML files are checked as well
+	if isHTML || ext == ".css" {
+		add(cssURLRefRe, false)
+		add(cssImportRefRe, false)
+	}
+	if isHTML || ext == ".js" || ext == ".mjs" {
+		add(jsFromRefRe, true)
+		add(jsImportRefRe, true)
+		add(jsDynImportRefRe, true)
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	result := spans[:0]
+	end := -1
+	for _, span := range spans {
+		if span.start >= end {
+			result = append(result, span)
+			end = span.end
+		}
+	}
+	return result
+}
+
+// rewriteReference returns the reference pointing at the new location of
+// its target, as seen from newDir. External links, anchors and references
+// leaving the project are left alone.
+func rewriteReference(ref string, module bool, oldDir, newDir string, moved func(string) string) (string, bool) {
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "//") ||
+		urlSchemeRe.MatchString(ref) || strings.Contains(ref, "{{") || strings.Contains(ref, "${") {
+		return "", false
+	}
+	if module && !strings.HasPrefix(ref, "./") && !strings.HasPrefix(ref, "../") && !strings.HasPrefix(ref, "/") {
+		return "", false
+	}
+
+	refPath, suffix := ref, ""
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		refPath, suffix = ref[:i], ref[i:]
+	}
+	decoded, err := url.PathUnescape(refPath)
+	if err != nil || decoded == "" {
+		return "", false
+	}
+	trailingSlash := strings.HasSuffix(decoded, "/")
+
+	if strings.HasPrefix(decoded, "/") {
+		target := strings.TrimPrefix(path.Clean(decoded), "/")
+		newTarget := moved(target)
+		if newTarget == target {
+			return ref, true
+		}
+		newRef := "/" + newTarget
+		if trailingSlash && newTarget != "" {
+			newRef += "/"
+		}
+		return escapeReference(newRef) + suffix, true
+	}
+
+	target := path.Join(oldDir, decoded)
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	newTarget := moved(target)
+	newRef := relativeReference(newDir, newTarget)
+	if newRef == relativeReference(oldDir, target) {
+		return ref, true
+	}
+	if trailingSlash && newRef != "." {
+		newRef += "/"
+	}
+	if (strings.HasPrefix(ref, "./") || module) && !strings.HasPrefix(newRef, ".") {
+		newRef = "./" + newRef
+	}
+	return escapeReference(newRef) + suffix, true
+}
+
+// relativeReference returns the relative path from the folder dir to target,
+// both relative to the project root
+func relativeReference(dir, target string) string {
+	split := func(p string) []string {
+		if p == "." || p == "" {
+			return nil
+		}
+		return strings.Split(p, "/")
+	}
+	from, to := split(dir), split(target)
+
+	common := 0
+	for common < len(from) && common < len(to) && from[common] == to[common] {
+		common++
+	}
+	var parts []string
+	for range from[common:] {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, to[common:]...)
+	if len(parts) == 0 {
+		return "."
+	}
+	return strings.Join(parts, "/")
+}
+
+// escapeReference percent-encodes a path for use in a reference
+func escapeReference(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
@@ -0,0 +1,619 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// The SFTP server gives each user a view of their own projects: the root
+// folder lists one folder per project, named after it, and projects can
+// only be created or deleted from the web interface. The same rules as the
+// file API apply inside a project: files count towards the owner's quota,
+// deleted files go to the trash, text edits are kept in the file history
+// and the root index.html cannot be deleted or moved.
+//
+// Users log in with their username and either their account password or
+// one of their SSH keys.
+
+// ErrInvalidSSHKey is returned for a public key that cannot be parsed
+var ErrInvalidSSHKey = errors.New("invalid SSH public key")
+
+// sftpAuthTimeout limits how long a client may take to log in
+const sftpAuthTimeout = 30 * time.Second
+
+// Permissions extensions set on successful authentication
+const (
+	sftpUserIDExtension = "staticforge-user-id"
+	sftpKeyIDExtension  = "staticforge-ssh-key-id"
+)
+
+// ParseSSHPublicKey parses a public key in authorized_keys format and returns
+// it without its comment, along with its SHA256 fingerprint
+func ParseSSHPublicKey(raw string) (string, string, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(raw)))
+	if err != nil {
+		return "", "", ErrInvalidSSHKey
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))), ssh.FingerprintSHA256(key), nil
+}
+
+// StartSFTPServer listens for SFTP clients until the listener fails
+func StartSFTPServer(cfg *config.Config) error {
+	hostKey, err := loadSFTPHostKey(cfg.SFTP.HostKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load host key: %w", err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback:  sftpPasswordCallback,
+		PublicKeyCallback: sftpPublicKeyCallback,
+	}
+	serverConfig.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", cfg.GetSFTPAddr())
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	log.Printf("Starting SFTP server on %s", cfg.GetSFTPAddr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go serveSFTPConn(conn, serverConfig)
+	}
+}
+
+// loadSFTPHostKey reads the server's private host key, generating an
+// ed25519 key on first start
+func loadSFTPHostKey(keyPath string) (ssh.Signer, error) {
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	log.Printf("Generated SFTP host key at %s", keyPath)
+	return ssh.NewSignerFromKey(privateKey)
+}
+
+// sftpPasswordCallback authenticates a user with their account password
+func sftpPasswordCallback(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	var user models.User
+	if err := database.GetDB().Where("username = ?", conn.User()).First(&user).Error; err != nil {
+		return nil, errors.New("invalid credentials")
+	}
+	if !user.HasPassword() || !utils.CheckPassword(string(password), user.Password) || !user.IsActive {
+		return nil, errors.New("invalid credentials")
+	}
+	return &ssh.Permissions{Extensions: map[string]string{
+		sftpUserIDExtension: strconv.FormatUint(uint64(user.ID), 10),
+	}}, nil
+}
+
+// sftpPublicKeyCallback authenticates a user with one of their SSH keys
+func sftpPublicKeyCallback(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	var sshKey models.SSHKey
+	if err := database.GetDB().Preload("User").Where("fingerprint = ?", ssh.FingerprintSHA256(key)).First(&sshKey).Error; err != nil {
+		return nil, errors.New("unknown public key")
+	}
+	if sshKey.User.Username != conn.User() || !sshKey.User.IsActive {
+		return nil, errors.New("unknown public key")
+	}
+	return &ssh.Permissions{Extensions: map[string]string{
+		sftpUserIDExtension: strconv.FormatUint(uint64(sshKey.UserID), 10),
+		sftpKeyIDExtension:  strconv.FormatUint(uint64(sshKey.ID), 10),
+	}}, nil
+}
+
+// serveSFTPConn runs the SSH handshake and serves the sftp subsystem on the
+// sessions of a client connection
+func serveSFTPConn(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(sftpAuthTimeout))
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	conn.SetDeadline(time.Time{})
+	go ssh.DiscardRequests(requests)
+
+	userID, _ := strconv.ParseUint(sshConn.Permissions.Extensions[sftpUserIDExtension], 10, 64)
+	if keyID := sshConn.Permissions.Extensions[sftpKeyIDExtension]; keyID != "" {
+		database.GetDB().Model(&models.SSHKey{}).Where("id = ?", keyID).Update("last_used_at", time.Now())
+	}
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSFTPSession(channel, channelRequests, uint(userID))
+	}
+}
+
+// serveSFTPSession serves the sftp subsystem on a session; shells and
+// commands are refused
+func serveSFTPSession(channel ssh.Channel, requests <-chan *ssh.Request, userID uint) {
+	defer channel.Close()
+
+	for req := range requests {
+		// The subsystem payload is the length-prefixed name
+		if req.Type != "subsystem" || len(req.Payload) < 4 || string(req.Payload[4:]) != "sftp" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+
+		userFS := &userSFTPFS{userID: userID}
+		server := sftp.NewRequestServer(channel, sftp.Handlers{
+			FileGet:  userFS,
+			FilePut:  userFS,
+			FileCmd:  userFS,
+			FileList: userFS,
+		})
+		if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+			log.Printf("SFTP session of user %d ended: %v", userID, err)
+		}
+		server.Close()
+		return
+	}
+}
+
+// userSFTPFS implements the sftp request handlers over the projects of a user
+type userSFTPFS struct {
+	userID uint
+}
+
+// sftpTarget is an SFTP path resolved to a project
+type sftpTarget struct {
+	project     *models.Project
+	projectPath string // storage path of the project
+	relPath     string // path inside the project, empty for its root
+}
+
+func (t *sftpTarget) fullPath() string {
+	return path.Join(t.projectPath, t.relPath)
+}
+
+// resolve loads the project an SFTP path points into. It returns a nil
+// target for the root folder.
+func (f *userSFTPFS) resolve(name string) (*sftpTarget, error) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil, nil
+	}
+
+	projectName, relPath, _ := strings.Cut(name, "/")
+	var project models.Project
+	if err := database.GetDB().Preload("User").Where("name = ? AND user_id = ?", projectName, f.userID).First(&project).Error; err != nil {
+		return nil, os.ErrNotExist
+	}
+	if !project.User.IsActive {
+		return nil, sftp.ErrSSHFxPermissionDenied
+	}
+
+	return &sftpTarget{
+		project:     &project,
+		projectPath: project.GetStoragePath(project.User.Username),
+		relPath:     relPath,
+	}, nil
+}
+
+// resolveInProject resolves a path below the root of a project, which is
+// the only place files can be changed
+func (f *userSFTPFS) resolveInProject(name string) (*sftpTarget, error) {
+	if path.Dir(path.Clean("/"+name)) == "/" {
+		return nil, sftp.ErrSSHFxPermissionDenied
+	}
+	return f.resolve(name)
+}
+
+func (f *userSFTPFS) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	target, err := f.resolveInProject(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	file, err := storage.Store.Open(target.fullPath())
+	if err != nil {
+		return nil, err
+	}
+	return &sftpReadFile{file: file}, nil
+}
+
+func (f *userSFTPFS) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	target, err := f.resolveInProject(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := storage.Store.Stat(target.fullPath())
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return nil, err
+	}
+	flags := r.Pflags()
+	if info != nil && (info.IsDir || flags.Excl) {
+		return nil, os.ErrExist
+	}
+	if info == nil && !flags.Creat {
+		return nil, os.ErrNotExist
+	}
+	if parent, err := storage.Store.Stat(path.Dir(target.fullPath())); err != nil || !parent.IsDir {
+		return nil, os.ErrNotExist
+	}
+
+	// Writes are staged in a temporary file and stored on Close
+	tmp, err := os.CreateTemp("", "staticforge-sftp-*")
+	if err != nil {
+		return nil, err
+	}
+	file := &sftpWriteFile{target: target, userID: f.userID, tmp: tmp}
+
+	// Resumed and appending uploads continue from the current content
+	if info != nil && !flags.Trunc {
+		existing, err := storage.Store.Open(target.fullPath())
+		if err == nil {
+			file.size, err = io.Copy(tmp, existing)
+			existing.Close()
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func (f *userSFTPFS) Filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		// Permissions and times are not kept, but clients set them after uploads
+		return nil
+	case "Mkdir":
+		return f.mkdir(r.Filepath)
+	case "Remove":
+		return f.remove(r.Filepath, false)
+	case "Rmdir":
+		return f.remove(r.Filepath, true)
+	case "Rename":
+		return f.rename(r.Filepath, r.Target, false)
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// PosixRename renames a file, replacing an existing file at the target
+func (f *userSFTPFS) PosixRename(r *sftp.Request) error {
+	return f.rename(r.Filepath, r.Target, true)
+}
+
+func (f *userSFTPFS) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	switch r.Method {
+	case "List":
+		return f.list(r.Filepath)
+	case "Stat":
+		info, err := f.stat(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return sftpLister{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+func (f *userSFTPFS) stat(name string) (os.FileInfo, error) {
+	target, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return sftpFileInfo{&storage.FileInfo{Name: "/", IsDir: true, ModTime: time.Now()}}, nil
+	}
+	if target.relPath == "" {
+		return projectSFTPFileInfo(target.project), nil
+	}
+
+	info, err := storage.Store.Stat(target.fullPath())
+	if err != nil {
+		return nil, err
+	}
+	return sftpFileInfo{info}, nil
+}
+
+func (f *userSFTPFS) list(name string) (sftp.ListerAt, error) {
+	target, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The root folder lists the user's projects
+	if target == nil {
+		var projects []models.Project
+		if err := database.GetDB().Where("user_id = ?", f.userID).Order("name").Find(&projects).Error; err != nil {
+			return nil, err
+		}
+		var entries sftpLister
+		for i := range projects {
+			entries = append(entries, projectSFTPFileInfo(&projects[i]))
+		}
+		return entries, nil
+	}
+
+	if target.relPath != "" {
+		info, err := storage.Store.Stat(target.fullPath())
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir {
+			return nil, sftp.ErrSSHFxFailure
+		}
+	}
+
+	list, err := storage.Store.List(target.fullPath())
+	if err != nil {
+		return nil, err
+	}
+	var entries sftpLister
+	for i := range list {
+		// List is recursive; only direct children belong to this folder
+		if !strings.Contains(list[i].Path, "/") {
+			entries = append(entries, sftpFileInfo{&list[i]})
+		}
+	}
+	return entries, nil
+}
+
+func (f *userSFTPFS) mkdir(name string) error {
+	target, err := f.resolveInProject(name)
+	if err != nil {
+		return err
+	}
+	if storage.Exists(storage.Store, target.fullPath()) {
+		return os.ErrExist
+	}
+	// Like mkdir, only the last element is created
+	if parent, err := storage.Store.Stat(path.Dir(target.fullPath())); err != nil || !parent.IsDir {
+		return os.ErrNotExist
+	}
+	return storage.Store.Mkdir(target.fullPath())
+}
+
+// remove moves a file, or an empty folder when dir is set, to the trash
+func (f *userSFTPFS) remove(name string, dir bool) error {
+	target, err := f.resolveInProject(name)
+	if err != nil {
+		return err
+	}
+
+	// Prevent deleting index.html
+	if target.relPath == "index.html" {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	info, err := storage.Store.Stat(target.fullPath())
+	if err != nil {
+		return err
+	}
+	if info.IsDir != dir {
+		return sftp.ErrSSHFxFailure
+	}
+	if dir {
+		entries, err := storage.Store.List(target.fullPath())
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return errors.New("directory not empty")
+		}
+	}
+
+	return trashSFTPTarget(target, f.userID)
+}
+
+// rename moves a file or folder inside a project. With replace set, an
+// existing file at the target is moved to the trash first.
+func (f *userSFTPFS) rename(oldName, newName string, replace bool) error {
+	source, err := f.resolveInProject(oldName)
+	if err != nil {
+		return err
+	}
+	target, err := f.resolveInProject(newName)
+	if err != nil {
+		return err
+	}
+	if source.project.ID != target.project.ID {
+		return sftp.ErrSSHFxOpUnsupported
+	}
+	if strings.HasPrefix(target.relPath+"/", source.relPath+"/") {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+
+	// Prevent moving index.html, or replacing it with a folder
+	if source.relPath == "index.html" {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	info, err := storage.Store.Stat(source.fullPath())
+	if err != nil {
+		return err
+	}
+	if target.relPath == "index.html" && info.IsDir {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	if parent, err := storage.Store.Stat(path.Dir(target.fullPath())); err != nil || !parent.IsDir {
+		return os.ErrNotExist
+	}
+
+	// A file getting another extension must pass the owner's content policy
+	if !info.IsDir && !strings.EqualFold(path.Ext(source.relPath), path.Ext(target.relPath)) {
+		if _, err := CheckStoredContentPolicy(&source.project.User, source.fullPath(), target.relPath); err != nil {
+			return sftp.ErrSSHFxPermissionDenied
+		}
+	}
+
+	if existing, err := storage.Store.Stat(target.fullPath()); err == nil {
+		if !replace || existing.IsDir || info.IsDir {
+			return os.ErrExist
+		}
+		if err := trashSFTPTarget(target, f.userID); err != nil {
+			return err
+		}
+	}
+
+	if err := storage.Store.Rename(source.fullPath(), target.fullPath()); err != nil {
+		return err
+	}
+	PublishFileChange(source.project.ID, FileChange{Type: FileChangeMove, Path: target.relPath, OldPath: source.relPath})
+
+	// Keep the file history with the moved files
+	if err := MoveRevisions(source.project.ID, source.relPath, target.relPath); err != nil {
+		log.Printf("Failed to move revisions of %s: %v", source.relPath, err)
+	}
+	return nil
+}
+
+// trashSFTPTarget keeps the last content of a file or folder in the file
+// history and moves it to the trash
+func trashSFTPTarget(target *sftpTarget, userID uint) error {
+	if err := RecordDeletedRevisions(target.project.ID, target.projectPath, target.relPath, userID); err != nil {
+		return err
+	}
+	_, err := MoveToTrash(target.project.ID, target.projectPath, target.relPath, userID)
+	return err
+}
+
+// sftpFileInfo adapts a storage.FileInfo to os.FileInfo
+type sftpFileInfo struct {
+	info *storage.FileInfo
+}
+
+func (i sftpFileInfo) Name() string       { return i.info.Name }
+func (i sftpFileInfo) Size() int64        { return i.info.Size }
+func (i sftpFileInfo) ModTime() time.Time { return i.info.ModTime }
+func (i sftpFileInfo) IsDir() bool        { return i.info.IsDir }
+func (i sftpFileInfo) Sys() any           { return nil }
+
+func (i sftpFileInfo) Mode() os.FileMode {
+	if i.info.IsDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// projectSFTPFileInfo describes the folder of a project in the root folder
+func projectSFTPFileInfo(project *models.Project) os.FileInfo {
+	return sftpFileInfo{&storage.FileInfo{Name: project.Name, IsDir: true, ModTime: project.UpdatedAt}}
+}
+
+// sftpLister is a folder listing or a single stat result
+type sftpLister []os.FileInfo
+
+func (l sftpLister) ListAt(entries []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(entries, l[offset:])
+	if n < len(entries) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// sftpReadFile serves random access reads from a storage file
+type sftpReadFile struct {
+	mu   sync.Mutex
+	file storage.File
+}
+
+func (f *sftpReadFile) ReadAt(p []byte, offset int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f.file, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *sftpReadFile) Close() error {
+	return f.file.Close()
+}
+
+// sftpWriteFile is a project file opened for writing. Content is staged in
+// a local temporary file and saved with SaveProjectFile on Close.
+type sftpWriteFile struct {
+	mu     sync.Mutex
+	target *sftpTarget
+	userID uint
+	tmp    *os.File
+	size   int64
+}
+
+func (f *sftpWriteFile) WriteAt(p []byte, offset int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	end := offset + int64(len(p))
+	if end > config.GetConfig().Upload.MaxSize {
+		return 0, errors.New("file exceeds maximum size")
+	}
+	n, err := f.tmp.WriteAt(p, offset)
+	f.size = max(f.size, offset+int64(n))
+	return n, err
+}
+
+func (f *sftpWriteFile) Close() error {
+	defer os.Remove(f.tmp.Name())
+	defer f.tmp.Close()
+
+	return SaveProjectFile(f.target.project, f.target.projectPath, f.target.relPath, f.tmp, f.size, f.userID)
+}
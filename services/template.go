@@ -0,0 +1,272 @@
+package services
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"html"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+	"github.com/itsHenry35/StaticForge/utils"
+)
+
+// DefaultTemplate is the template of projects created without choosing one
+const DefaultTemplate = "default"
+
+// builtinTemplates holds one folder per built-in template with its
+// template.json metadata, an optional preview image and the project files
+// below files/
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+var (
+	// ErrTemplateNotFound is returned for unknown template names
+	ErrTemplateNotFound = errors.New("template not found")
+
+	// ErrTemplateExists is returned when uploading a template under a name
+	// that is already taken
+	ErrTemplateExists = errors.New("template already exists")
+)
+
+// TemplateFile is a file of a project template
+type TemplateFile struct {
+	Path    string
+	Content []byte
+}
+
+// TemplateVars are the values substituted into template files as
+// {{project_name}}, {{display_name}} and {{owner}}
+type TemplateVars struct {
+	ProjectName string
+	DisplayName string
+	Owner       string
+}
+
+// builtinTemplateMeta is the template.json of a built-in template
+type builtinTemplateMeta struct {
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	Preview     string `json:"preview"`
+}
+
+// ListProjectTemplates returns the built-in templates followed by the
+// uploaded ones
+func ListProjectTemplates() ([]models.ProjectTemplate, error) {
+	templates, err := listBuiltinTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var uploaded []models.ProjectTemplate
+	if err := database.GetDB().Order("name").Find(&uploaded).Error; err != nil {
+		return nil, err
+	}
+	return append(templates, uploaded...), nil
+}
+
+// GetProjectTemplate returns a built-in or uploaded template by name
+func GetProjectTemplate(name string) (*models.ProjectTemplate, error) {
+	if template, err := getBuiltinTemplate(name); err == nil {
+		return template, nil
+	}
+
+	var template models.ProjectTemplate
+	if err := database.GetDB().Where("name = ?", name).First(&template).Error; err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	return &template, nil
+}
+
+// ReadTemplateFiles returns the files of a template
+func ReadTemplateFiles(template *models.ProjectTemplate) ([]TemplateFile, error) {
+	var files []TemplateFile
+	if template.IsBuiltIn() {
+		root := path.Join("templates", template.Name, "files")
+		err := fs.WalkDir(builtinTemplates, root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			content, err := builtinTemplates.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			files = append(files, TemplateFile{Path: strings.TrimPrefix(name, root+"/"), Content: content})
+			return nil
+		})
+		return files, err
+	}
+
+	root := path.Join(template.GetStoragePath(), "files")
+	entries, err := storage.Store.List(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir {
+			continue
+		}
+		content, err := storage.Store.Read(path.Join(root, entry.Path))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, TemplateFile{Path: entry.Path, Content: content})
+	}
+	return files, nil
+}
+
+// ReadTemplatePreview returns the preview image of a template and its file
+// name, or ErrTemplateNotFound if it has none
+func ReadTemplatePreview(template *models.ProjectTemplate) ([]byte, string, error) {
+	if template.PreviewName == "" {
+		return nil, "", ErrTemplateNotFound
+	}
+
+	var content []byte
+	var err error
+	if template.IsBuiltIn() {
+		content, err = builtinTemplates.ReadFile(path.Join("templates", template.Name, template.PreviewName))
+	} else {
+		content, err = storage.Store.Read(path.Join(template.GetStoragePath(), template.PreviewName))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return content, template.PreviewName, nil
+}
+
+// RenderTemplateFiles substitutes the template variables into the text
+// files of a template. Values are HTML-escaped in markup files.
+func RenderTemplateFiles(files []TemplateFile, vars TemplateVars) []TemplateFile {
+	plain := templateReplacer(vars, func(s string) string { return s })
+	markup := templateReplacer(vars, html.EscapeString)
+
+	rendered := make([]TemplateFile, len(files))
+	for i, file := range files {
+		rendered[i] = file
+		if !utils.IsTextFile(file.Path, file.Content) {
+			continue
+		}
+		replacer := plain
+		switch strings.ToLower(path.Ext(file.Path)) {
+		case ".html", ".htm", ".xml", ".svg":
+			replacer = markup
+		}
+		rendered[i].Content = []byte(replacer.Replace(string(file.Content)))
+	}
+	return rendered
+}
+
+// CreateProjectTemplate stores the files and preview image of an uploaded
+// template and saves its record. template.Name must be validated.
+func CreateProjectTemplate(template *models.ProjectTemplate, files []TemplateFile, preview []byte) error {
+	if _, err := GetProjectTemplate(template.Name); err == nil {
+		return ErrTemplateExists
+	}
+
+	// Files left over from a failed upload are replaced
+	root := template.GetStoragePath()
+	if err := storage.Store.Delete(root); err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return err
+	}
+
+	template.FileCount, template.Size = 0, 0
+	for _, file := range files {
+		if err := storage.WriteFile(storage.Store, path.Join(root, "files", file.Path), file.Content); err != nil {
+			return err
+		}
+		template.FileCount++
+		template.Size += int64(len(file.Content))
+	}
+	if template.PreviewName != "" {
+		if err := storage.WriteFile(storage.Store, path.Join(root, template.PreviewName), preview); err != nil {
+			return err
+		}
+	}
+
+	return database.GetDB().Create(template).Error
+}
+
+// DeleteProjectTemplate removes an uploaded template and its files
+func DeleteProjectTemplate(template *models.ProjectTemplate) error {
+	if err := database.GetDB().Delete(template).Error; err != nil {
+		return err
+	}
+	if err := storage.Store.Delete(template.GetStoragePath()); err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func listBuiltinTemplates() ([]models.ProjectTemplate, error) {
+	entries, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []models.ProjectTemplate
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		template, err := getBuiltinTemplate(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *template)
+	}
+
+	// The default template comes first
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Name == DefaultTemplate && templates[j].Name != DefaultTemplate
+	})
+	return templates, nil
+}
+
+func getBuiltinTemplate(name string) (*models.ProjectTemplate, error) {
+	if name == "" || strings.ContainsAny(name, "/\\.") {
+		return nil, ErrTemplateNotFound
+	}
+	data, err := builtinTemplates.ReadFile(path.Join("templates", name, "template.json"))
+	if err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	var meta builtinTemplateMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+
+	template := &models.ProjectTemplate{
+		Name:        name,
+		DisplayName: meta.DisplayName,
+		Description: meta.Description,
+		PreviewName: meta.Preview,
+	}
+	err = fs.WalkDir(builtinTemplates, path.Join("templates", name, "files"), func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		template.FileCount++
+		template.Size += info.Size()
+		return nil
+	})
+	return template, err
+}
+
+func templateReplacer(vars TemplateVars, escape func(string) string) *strings.Replacer {
+	return strings.NewReplacer(
+		"{{project_name}}", escape(vars.ProjectName),
+		"{{display_name}}", escape(vars.DisplayName),
+		"{{owner}}", escape(vars.Owner),
+	)
+}
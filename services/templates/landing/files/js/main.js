@@ -0,0 +1 @@
+document.getElementById('year').textContent = new Date().getFullYear();
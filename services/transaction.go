@@ -0,0 +1,162 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/itsHenry35/StaticForge/storage"
+)
+
+// TransactionsRoot is the storage folder holding backups of running file
+// transactions. Usernames cannot start with a dot, so it never clashes with
+// user folders.
+const TransactionsRoot = ".transactions"
+
+// FileTransaction applies a series of storage changes that can be undone as
+// a whole. Replaced and deleted files are moved to a backup folder instead of
+// being removed, and every change records how to revert it.
+type FileTransaction struct {
+	backupPath string
+	backups    int
+	undo       []func() error
+}
+
+// BeginFileTransaction starts a new file transaction
+func BeginFileTransaction() (*FileTransaction, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &FileTransaction{
+		backupPath: path.Join(TransactionsRoot, hex.EncodeToString(id)),
+	}, nil
+}
+
+// Write creates or replaces a file
+func (t *FileTransaction) Write(name string, content []byte) error {
+	if err := t.backup(name); err != nil {
+		return err
+	}
+	t.undo = append(t.undo, t.trackCreated(name))
+	return storage.WriteFile(storage.Store, name, content)
+}
+
+// WriteFrom creates or replaces a file with size bytes read from r
+func (t *FileTransaction) WriteFrom(name string, r io.Reader, size int64) error {
+	if err := t.backup(name); err != nil {
+		return err
+	}
+	t.undo = append(t.undo, t.trackCreated(name))
+	return storage.Store.Write(name, r, size)
+}
+
+// Mkdir creates a folder and any missing parents. A folder that already
+// exists is left alone, including on rollback.
+func (t *FileTransaction) Mkdir(name string) error {
+	if storage.Exists(storage.Store, name) {
+		return storage.Store.Mkdir(name)
+	}
+	t.undo = append(t.undo, t.trackCreated(name))
+	return storage.Store.Mkdir(name)
+}
+
+// Delete removes a file or folder
+func (t *FileTransaction) Delete(name string) error {
+	return t.backup(name)
+}
+
+// Move moves a file or folder, replacing anything at the destination
+func (t *FileTransaction) Move(src, dst string) error {
+	if err := t.backup(dst); err != nil {
+		return err
+	}
+	created := t.trackCreated(dst)
+	if err := storage.Store.Rename(src, dst); err != nil {
+		return err
+	}
+	t.undo = append(t.undo, func() error {
+		if err := storage.Store.Rename(dst, src); err != nil {
+			return err
+		}
+		return created()
+	})
+	return nil
+}
+
+// Copy copies a file or folder, replacing anything at the destination
+func (t *FileTransaction) Copy(src, dst string) error {
+	if err := t.backup(dst); err != nil {
+		return err
+	}
+	t.undo = append(t.undo, t.trackCreated(dst))
+	return storage.CopyTree(storage.Store, src, dst)
+}
+
+// Commit finishes the transaction and discards the backups
+func (t *FileTransaction) Commit() error {
+	t.undo = nil
+	return t.cleanup()
+}
+
+// Rollback reverts every change in reverse order and discards the backups
+func (t *FileTransaction) Rollback() error {
+	var errs []error
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		if err := t.undo[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.undo = nil
+
+	// Keep the backups if anything could not be restored
+	if len(errs) > 0 {
+		return fmt.Errorf("rollback incomplete, backups kept in %s: %w", t.backupPath, errors.Join(errs...))
+	}
+	return t.cleanup()
+}
+
+// backup moves an existing file or folder out of the way so it can be restored
+func (t *FileTransaction) backup(name string) error {
+	if !storage.Exists(storage.Store, name) {
+		return nil
+	}
+
+	t.backups++
+	backupName := path.Join(t.backupPath, fmt.Sprint(t.backups))
+	if err := storage.Store.Rename(name, backupName); err != nil {
+		return err
+	}
+	t.undo = append(t.undo, func() error {
+		return storage.Store.Rename(backupName, name)
+	})
+	return nil
+}
+
+// trackCreated returns an undo function removing name together with any of
+// its parent folders that do not exist yet
+func (t *FileTransaction) trackCreated(name string) func() error {
+	top := name
+	for parent := path.Dir(top); parent != "." && parent != "/" && !storage.Exists(storage.Store, parent); parent = path.Dir(parent) {
+		top = parent
+	}
+	return func() error {
+		err := storage.Store.Delete(top)
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+}
+
+// cleanup removes the backup folder
+func (t *FileTransaction) cleanup() error {
+	err := storage.Store.Delete(t.backupPath)
+	if errors.Is(err, storage.ErrNotExist) {
+		return nil
+	}
+	return err
+}
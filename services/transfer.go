@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+)
+
+// ErrTransferTargetExists is returned when the recipient's storage already
+// holds a folder with the project's name
+var ErrTransferTargetExists = errors.New("recipient already has a folder for the project")
+
+// TransferProject hands a project over to recipient: its folder moves to the
+// recipient's storage and its owner is updated. The project keeps its ID and
+// name, so deployments, history, trash, analytics and the published URL are
+// unchanged. Deploy tokens issued under the previous owner are revoked, a
+// linked git remote and its deploy hook are unlinked, and pending transfers
+// of the project are removed. The files, including those in the trash, are
+// checked against the recipient's quota and content policy. project.User
+// must be loaded.
+func TransferProject(project *models.Project, recipient *models.User) error {
+	oldPath := project.GetStoragePath(project.User.Username)
+	newPath := project.GetStoragePath(recipient.Username)
+
+	usage, err := getProjectUsage(project, project.User.Username)
+	if err != nil {
+		return err
+	}
+	if err := CheckQuota(recipient, usage.Bytes, usage.Files); err != nil {
+		return err
+	}
+	if usage.Files > 0 {
+		if _, err := CheckStoredContentPolicy(recipient, oldPath, ""); err != nil {
+			return err
+		}
+	}
+
+	// Trash items can be restored by the recipient, so they are checked too
+	var trashItems []models.TrashItem
+	if err := database.GetDB().Where("project_id = ?", project.ID).Find(&trashItems).Error; err != nil {
+		return err
+	}
+	for _, item := range trashItems {
+		if _, err := CheckStoredContentPolicy(recipient, item.GetStoragePath(), item.OriginalPath); err != nil && !errors.Is(err, storage.ErrNotExist) {
+			return err
+		}
+	}
+
+	if storage.Exists(storage.Store, newPath) {
+		return ErrTransferTargetExists
+	}
+	moved := storage.Exists(storage.Store, oldPath)
+	if moved {
+		if err := storage.Store.Rename(oldPath, newPath); err != nil {
+			return err
+		}
+	}
+
+	// The git remote may hold the previous owner's credentials, and the
+	// hook URL was handed out by them. The loaded owner would be saved back
+	// along with the project, so the columns are updated on their own.
+	updates := map[string]interface{}{
+		"user_id":         recipient.ID,
+		"git_remote_url":  "",
+		"git_hook_token":  "",
+		"git_sync_status": "",
+		"git_sync_error":  "",
+		"git_synced_at":   nil,
+	}
+	if err := database.GetDB().Model(&models.Project{}).Where("id = ?", project.ID).Updates(updates).Error; err != nil {
+		if moved {
+			if rbErr := storage.Store.Rename(newPath, oldPath); rbErr != nil {
+				log.Printf("Failed to move project %s back after a failed transfer: %v", project.Name, rbErr)
+			}
+		}
+		return err
+	}
+	addCachedUsage(project.User.ID, -usage.Bytes, -usage.Files)
+	project.UserID = recipient.ID
+	project.User = *recipient
+	project.GitRemoteURL = ""
+	project.GitHookToken = ""
+	project.GitSyncStatus = ""
+	project.GitSyncError = ""
+	project.GitSyncedAt = nil
+
+	if err := database.GetDB().Where("project_id = ?", project.ID).Delete(&models.DeployToken{}).Error; err != nil {
+		log.Printf("Failed to revoke deploy tokens of project %s: %v", project.Name, err)
+	}
+	if err := DeleteProjectTransfers(project.ID); err != nil {
+		log.Printf("Failed to delete transfers of project %s: %v", project.Name, err)
+	}
+	return nil
+}
+
+// DeleteProjectTransfers removes the pending transfers of a project
+func DeleteProjectTransfers(projectID uint) error {
+	return database.GetDB().Where("project_id = ?", projectID).Delete(&models.ProjectTransfer{}).Error
+}
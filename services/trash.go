@@ -0,0 +1,154 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+)
+
+// ErrTrashRestoreConflict is returned when the original location of a trash
+// item is taken and overwriting was not requested
+var ErrTrashRestoreConflict = errors.New("restore target already exists")
+
+// NewTrashItem prepares an unsaved trash record for a project file or folder.
+// The item has to be moved to its storage path and saved with SaveTrashItem.
+func NewTrashItem(projectID uint, projectPath, relPath string, userID uint) (*models.TrashItem, error) {
+	fullPath := path.Join(projectPath, relPath)
+	info, err := storage.Store.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	item := models.TrashItem{
+		ProjectID:    projectID,
+		OriginalPath: relPath,
+		StorageKey:   hex.EncodeToString(key),
+		IsDir:        info.IsDir,
+		Size:         info.Size,
+		FileCount:    1,
+		DeletedBy:    userID,
+	}
+
+	if info.IsDir {
+		entries, err := storage.Store.List(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		item.Size, item.FileCount = 0, 0
+		for _, entry := range entries {
+			if !entry.IsDir {
+				item.Size += entry.Size
+				item.FileCount++
+			}
+		}
+	}
+
+	return &item, nil
+}
+
+// SaveTrashItem stores the record of an item already moved to the trash
+func SaveTrashItem(item *models.TrashItem) error {
+	return database.GetDB().Create(item).Error
+}
+
+// MoveToTrash moves a project file or folder to the project's trash
+func MoveToTrash(projectID uint, projectPath, relPath string, userID uint) (*models.TrashItem, error) {
+	item, err := NewTrashItem(projectID, projectPath, relPath, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.Store.Rename(path.Join(projectPath, relPath), item.GetStoragePath()); err != nil {
+		return nil, fmt.Errorf("failed to move to trash: %w", err)
+	}
+
+	if err := SaveTrashItem(item); err != nil {
+		// Put the file back rather than leaving it untracked in the trash
+		storage.Store.Rename(item.GetStoragePath(), path.Join(projectPath, relPath))
+		return nil, fmt.Errorf("failed to create trash item: %w", err)
+	}
+
+	PublishFileChange(projectID, FileChange{Type: FileChangeDelete, Path: relPath})
+	return item, nil
+}
+
+// RestoreTrashItem moves a trash item back to its original path. An existing
+// file or folder at that path is moved to the trash first when overwrite is set.
+func RestoreTrashItem(item *models.TrashItem, projectPath string, overwrite bool, userID uint) error {
+	target := path.Join(projectPath, item.OriginalPath)
+
+	// A file cannot be restored below another file
+	for dir := path.Dir(target); dir != projectPath && dir != "."; dir = path.Dir(dir) {
+		if info, err := storage.Store.Stat(dir); err == nil && !info.IsDir {
+			return ErrTrashRestoreConflict
+		}
+	}
+
+	if storage.Exists(storage.Store, target) {
+		if !overwrite {
+			return ErrTrashRestoreConflict
+		}
+		if _, err := MoveToTrash(item.ProjectID, projectPath, item.OriginalPath, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := storage.Store.Rename(item.GetStoragePath(), target); err != nil {
+		return fmt.Errorf("failed to restore from trash: %w", err)
+	}
+	PublishFileChange(item.ProjectID, FileChange{Type: FileChangeWrite, Path: item.OriginalPath})
+	return database.GetDB().Delete(item).Error
+}
+
+// PurgeTrashItem permanently deletes a trash item
+func PurgeTrashItem(item *models.TrashItem) error {
+	if err := storage.Store.Delete(item.GetStoragePath()); err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return err
+	}
+	return database.GetDB().Delete(item).Error
+}
+
+// DeleteProjectTrash permanently deletes all trash items of a project
+func DeleteProjectTrash(projectID uint) error {
+	trashPath := fmt.Sprintf("%s/%d", models.TrashRoot, projectID)
+	if storage.Exists(storage.Store, trashPath) {
+		if err := storage.Store.Delete(trashPath); err != nil {
+			return err
+		}
+	}
+	return database.GetDB().Where("project_id = ?", projectID).Delete(&models.TrashItem{}).Error
+}
+
+// CleanupExpiredTrash purges trash items older than the configured retention
+func CleanupExpiredTrash() error {
+	retentionDays := config.GetConfig().Trash.RetentionDays
+	if retentionDays <= 0 {
+		return nil
+	}
+
+	var items []models.TrashItem
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	if err := database.GetDB().Where("created_at < ?", cutoff).Find(&items).Error; err != nil {
+		return err
+	}
+	for i := range items {
+		if err := PurgeTrashItem(&items[i]); err != nil {
+			log.Printf("Failed to purge trash item %d: %v", items[i].ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/itsHenry35/StaticForge/database"
+	"github.com/itsHenry35/StaticForge/models"
+	"github.com/itsHenry35/StaticForge/storage"
+)
+
+// UploadExpiry is how long an unfinished resumable upload is kept after it
+// last received data
+const UploadExpiry = 24 * time.Hour
+
+// UploadMaxAge is how long an unfinished resumable upload is kept at most,
+// however often it receives data
+const UploadMaxAge = 7 * 24 * time.Hour
+
+var (
+	// ErrUploadOffsetMismatch is returned when a chunk does not start at the current offset
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrUploadBusy is returned when another request is already writing to the upload
+	ErrUploadBusy = errors.New("upload is locked by another request")
+)
+
+// uploadLocks serializes chunk writes per upload session
+var uploadLocks sync.Map
+
+// getStagingDir returns the local folder holding partial uploads. It is not
+// shared between nodes, so every request of an upload has to reach the node
+// that created it.
+func getStagingDir() string {
+	dir := config.GetConfig().Upload.StagingDir
+	if dir == "" {
+		dir = "data/uploads"
+	}
+	return dir
+}
+
+// stagingPath returns the staging file of an upload session
+func stagingPath(session *models.UploadSession) string {
+	return filepath.Join(getStagingDir(), session.ID)
+}
+
+// CreateUploadSession registers a new resumable upload and creates its empty staging file
+func CreateUploadSession(projectID, userID uint, relPath string, length int64, overwrite bool) (*models.UploadSession, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	session := models.UploadSession{
+		ID:        hex.EncodeToString(id),
+		ProjectID: projectID,
+		UserID:    userID,
+		Path:      relPath,
+		Length:    length,
+		Overwrite: overwrite,
+	}
+
+	if err := os.MkdirAll(getStagingDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging folder: %w", err)
+	}
+	f, err := os.OpenFile(stagingPath(&session), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging file: %w", err)
+	}
+	f.Close()
+
+	if err := database.GetDB().Create(&session).Error; err != nil {
+		os.Remove(stagingPath(&session))
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// CheckUploadQuota checks a new resumable upload against the quota of the
+// project owner. The full length of the owner's unfinished uploads counts as
+// used, so staged data cannot go past the quota.
+func CheckUploadQuota(owner *models.User, addBytes, addFiles int64) error {
+	var pending int64
+	projectIDs := database.GetDB().Model(&models.Project{}).Select("id").Where("user_id = ?", owner.ID)
+	if err := database.GetDB().Model(&models.UploadSession{}).Where("project_id IN (?)", projectIDs).
+		Select("COALESCE(SUM(length), 0)").Scan(&pending).Error; err != nil {
+		return err
+	}
+	return checkQuota(owner, pending, addBytes, addFiles)
+}
+
+// GetUploadExpiry returns when an unfinished upload will be removed
+func GetUploadExpiry(session *models.UploadSession) time.Time {
+	expires := session.UpdatedAt.Add(UploadExpiry)
+	if maxAge := session.CreatedAt.Add(UploadMaxAge); maxAge.Before(expires) {
+		return maxAge
+	}
+	return expires
+}
+
+// GetUploadOffset returns the number of bytes received so far
+func GetUploadOffset(session *models.UploadSession) (int64, error) {
+	info, err := os.Stat(stagingPath(session))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// AppendUpload appends a chunk starting at offset to the staging file and
+// returns the new offset. Bytes beyond the announced length are ignored.
+// Whatever was received before a connection drop is kept, so the client can
+// resume from the returned offset.
+func AppendUpload(session *models.UploadSession, offset int64, r io.Reader) (int64, error) {
+	lock, _ := uploadLocks.LoadOrStore(session.ID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	if !mu.TryLock() {
+		return 0, ErrUploadBusy
+	}
+	defer mu.Unlock()
+
+	current, err := GetUploadOffset(session)
+	if err != nil {
+		return 0, err
+	}
+	if offset != current {
+		return current, ErrUploadOffsetMismatch
+	}
+
+	f, err := os.OpenFile(stagingPath(session), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return current, err
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(r, session.Length-current))
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	// Touch the session so active uploads do not expire
+	if n > 0 {
+		database.GetDB().Model(session).Update("updated_at", time.Now())
+	}
+
+	return current + n, copyErr
+}
+
+// CheckUploadContentPolicy checks the received content of a completed upload
+// against the content policy of the project owner
+func CheckUploadContentPolicy(owner *models.User, session *models.UploadSession) error {
+	f, err := os.Open(stagingPath(session))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = CheckContentPolicy(owner, session.Path, session.Length, f)
+	return err
+}
+
+// FinalizeUpload moves a completed upload into storage and removes the session.
+// The storage write replaces the target in one step, so readers never see a
+// partially uploaded file.
+func FinalizeUpload(session *models.UploadSession, fullPath string) error {
+	f, err := os.Open(stagingPath(session))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := storage.Store.Write(fullPath, f, session.Length); err != nil {
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+	PublishFileChange(session.ProjectID, FileChange{Type: FileChangeWrite, Path: session.Path})
+
+	return DeleteUploadSession(session)
+}
+
+// DeleteUploadSession removes an upload session and its staging file
+func DeleteUploadSession(session *models.UploadSession) error {
+	if err := os.Remove(stagingPath(session)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	uploadLocks.Delete(session.ID)
+	return database.GetDB().Delete(session).Error
+}
+
+// DeleteProjectUploads removes all unfinished uploads of a project
+func DeleteProjectUploads(projectID uint) error {
+	var sessions []models.UploadSession
+	if err := database.GetDB().Where("project_id = ?", projectID).Find(&sessions).Error; err != nil {
+		return err
+	}
+	for i := range sessions {
+		if err := DeleteUploadSession(&sessions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanupExpiredUploads removes uploads that received no data within
+// UploadExpiry or are older than UploadMaxAge
+func CleanupExpiredUploads() error {
+	now := time.Now()
+	var sessions []models.UploadSession
+	if err := database.GetDB().Where("updated_at < ? OR created_at < ?", now.Add(-UploadExpiry), now.Add(-UploadMaxAge)).Find(&sessions).Error; err != nil {
+		return err
+	}
+	for i := range sessions {
+		if err := DeleteUploadSession(&sessions[i]); err != nil {
+			log.Printf("Failed to remove expired upload %s: %v", sessions[i].ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// LocalStorage stores files on the local disk below a root directory
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a local storage rooted at dir
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: dir}, nil
+}
+
+// fullPath maps a storage key to a path on disk
+func (s *LocalStorage) fullPath(name string) string {
+	return filepath.Join(s.root, filepath.FromSlash(cleanName(name)))
+}
+
+// List returns every file and folder below dir
+func (s *LocalStorage) List(dir string) ([]FileInfo, error) {
+	root := s.fullPath(dir)
+	files := []FileInfo{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip the root directory itself
+		if p == root {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		files = append(files, FileInfo{
+			Path:    filepath.ToSlash(relPath),
+			Name:    d.Name(),
+			Size:    info.Size(),
+			IsDir:   d.IsDir(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Stat returns information about a file or folder
+func (s *LocalStorage) Stat(name string) (*FileInfo, error) {
+	info, err := os.Stat(s.fullPath(name))
+	if err != nil {
+		return nil, err
+	}
+	return &FileInfo{
+		Path:    cleanName(name),
+		Name:    info.Name(),
+		Size:    info.Size(),
+		IsDir:   info.IsDir(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// Read returns the whole content of a file
+func (s *LocalStorage) Read(name string) ([]byte, error) {
+	return os.ReadFile(s.fullPath(name))
+}
+
+// Write creates or replaces a file. Content is written to a temporary file
+// first and renamed into place so readers never see a partial file.
+func (s *LocalStorage) Write(name string, r io.Reader, size int64) error {
+	dst := s.fullPath(name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".sf-upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// Mkdir creates a folder and any missing parents
+func (s *LocalStorage) Mkdir(name string) error {
+	return os.MkdirAll(s.fullPath(name), 0755)
+}
+
+// Rename moves a file or folder to a new name
+func (s *LocalStorage) Rename(oldName, newName string) error {
+	dst := s.fullPath(newName)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(s.fullPath(oldName), dst)
+}
+
+// Copy copies a single file
+func (s *LocalStorage) Copy(src, dst string) error {
+	f, err := os.Open(s.fullPath(src))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.Write(dst, f, info.Size())
+}
+
+// Delete removes a file, or a folder and all of its contents
+func (s *LocalStorage) Delete(name string) error {
+	p := s.fullPath(name)
+	if _, err := os.Lstat(p); err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+// Open opens a file for reading with seek support
+func (s *LocalStorage) Open(name string) (File, error) {
+	return os.Open(s.fullPath(name))
+}
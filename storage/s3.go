@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/itsHenry35/StaticForge/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage stores files in an S3-compatible bucket (AWS S3, MinIO, ...).
+// Folders are represented by zero-byte marker objects whose key ends in "/",
+// and are also implied by any object stored below them.
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Storage creates an S3 storage and makes sure the bucket exists
+func NewS3Storage(cfg config.S3Config) (*S3Storage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket: %w", err)
+		}
+	}
+
+	return &S3Storage{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: cleanName(cfg.Prefix),
+	}, nil
+}
+
+// key maps a storage name to an object key
+func (s *S3Storage) key(name string) string {
+	if s.prefix == "" {
+		return cleanName(name)
+	}
+	return path.Join(s.prefix, cleanName(name))
+}
+
+// dirKey returns the key prefix of everything below a folder
+func (s *S3Storage) dirKey(name string) string {
+	k := s.key(name)
+	if k == "" {
+		return ""
+	}
+	return k + "/"
+}
+
+// convertError maps S3 "not found" responses to ErrNotExist
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return ErrNotExist
+	}
+	return err
+}
+
+// listKeys returns every object stored below a folder
+func (s *S3Storage) listKeys(ctx context.Context, name string) ([]minio.ObjectInfo, error) {
+	var objects []minio.ObjectInfo
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.dirKey(name),
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// List returns every file and folder below dir
+func (s *S3Storage) List(dir string) ([]FileInfo, error) {
+	objects, err := s.listKeys(context.Background(), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := s.dirKey(dir)
+	seen := make(map[string]bool)
+	files := []FileInfo{}
+	addDir := func(rel string, obj minio.ObjectInfo) {
+		if rel == "" || seen[rel] {
+			return
+		}
+		seen[rel] = true
+		files = append(files, FileInfo{
+			Path:    rel,
+			Name:    path.Base(rel),
+			IsDir:   true,
+			ModTime: obj.LastModified,
+		})
+	}
+
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, prefix)
+
+		// Register every parent folder implied by the key
+		parts := strings.Split(strings.TrimSuffix(rel, "/"), "/")
+		for i := 1; i < len(parts); i++ {
+			addDir(strings.Join(parts[:i], "/"), obj)
+		}
+
+		if strings.HasSuffix(rel, "/") {
+			addDir(strings.TrimSuffix(rel, "/"), obj)
+			continue
+		}
+
+		files = append(files, FileInfo{
+			Path:    rel,
+			Name:    path.Base(rel),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Stat returns information about a file or folder
+func (s *S3Storage) Stat(name string) (*FileInfo, error) {
+	ctx := context.Background()
+	name = cleanName(name)
+
+	obj, err := s.client.StatObject(ctx, s.bucket, s.key(name), minio.StatObjectOptions{})
+	if err == nil {
+		return &FileInfo{
+			Path:    name,
+			Name:    path.Base(name),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		}, nil
+	}
+	if convertError(err) != ErrNotExist {
+		return nil, err
+	}
+
+	// Not a file, check whether anything lives below it
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.dirKey(name),
+		Recursive: true,
+		MaxKeys:   1,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		return &FileInfo{
+			Path:    name,
+			Name:    path.Base(name),
+			IsDir:   true,
+			ModTime: obj.LastModified,
+		}, nil
+	}
+
+	return nil, ErrNotExist
+}
+
+// Read returns the whole content of a file
+func (s *S3Storage) Read(name string) ([]byte, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Write creates or replaces a file
+func (s *S3Storage) Write(name string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.key(name), r, size, minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+	})
+	return err
+}
+
+// Mkdir creates a folder marker object
+func (s *S3Storage) Mkdir(name string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.dirKey(name), bytes.NewReader(nil), 0, minio.PutObjectOptions{})
+	return err
+}
+
+// Rename moves a file or folder to a new name by copying and deleting
+func (s *S3Storage) Rename(oldName, newName string) error {
+	info, err := s.Stat(oldName)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir {
+		if err := s.Copy(oldName, newName); err != nil {
+			return err
+		}
+		return s.Delete(oldName)
+	}
+
+	ctx := context.Background()
+	objects, err := s.listKeys(ctx, oldName)
+	if err != nil {
+		return err
+	}
+
+	oldPrefix := s.dirKey(oldName)
+	newPrefix := s.dirKey(newName)
+	for _, obj := range objects {
+		if _, err := s.client.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: s.bucket, Object: newPrefix + strings.TrimPrefix(obj.Key, oldPrefix)},
+			minio.CopySrcOptions{Bucket: s.bucket, Object: obj.Key},
+		); err != nil {
+			return err
+		}
+	}
+	return s.Delete(oldName)
+}
+
+// Copy copies a single file using a server-side copy
+func (s *S3Storage) Copy(src, dst string) error {
+	_, err := s.client.CopyObject(context.Background(),
+		minio.CopyDestOptions{Bucket: s.bucket, Object: s.key(dst)},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: s.key(src)},
+	)
+	return convertError(err)
+}
+
+// Delete removes a file, or a folder and all of its contents
+func (s *S3Storage) Delete(name string) error {
+	info, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !info.IsDir {
+		return convertError(s.client.RemoveObject(ctx, s.bucket, s.key(name), minio.RemoveObjectOptions{}))
+	}
+
+	objects, err := s.listKeys(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	objectsCh := make(chan minio.ObjectInfo, len(objects))
+	for _, obj := range objects {
+		objectsCh <- obj
+	}
+	close(objectsCh)
+
+	// The error channel is drained, or the goroutine feeding it would block
+	var firstErr error
+	for rErr := range s.client.RemoveObjects(ctx, s.bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		if firstErr == nil {
+			firstErr = rErr.Err
+		}
+	}
+	return convertError(firstErr)
+}
+
+// Open opens a file for reading with seek support
+func (s *S3Storage) Open(name string) (File, error) {
+	obj, err := s.client.GetObject(context.Background(), s.bucket, s.key(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	// GetObject is lazy, stat the object to surface missing keys now
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, convertError(err)
+	}
+	return obj, nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/itsHenry35/StaticForge/config"
+)
+
+// ErrNotExist is returned when a file or folder does not exist in the store
+var ErrNotExist = fs.ErrNotExist
+
+// FileInfo describes a file or folder held by a storage backend
+type FileInfo struct {
+	Path    string // slash-separated path relative to the listed directory
+	Name    string
+	Size    int64
+	IsDir   bool
+	ModTime time.Time
+}
+
+// File is an open file that supports random access for range requests
+type File interface {
+	io.ReadSeekCloser
+}
+
+// Storage is implemented by every project file backend.
+// All names are slash-separated keys relative to the storage root,
+// e.g. "{username}/{projectName}/css/style.css".
+type Storage interface {
+	// List returns every file and folder below dir, with paths relative to dir
+	List(dir string) ([]FileInfo, error)
+	// Stat returns information about a file or folder
+	Stat(name string) (*FileInfo, error)
+	// Read returns the whole content of a file
+	Read(name string) ([]byte, error)
+	// Write creates or replaces a file, creating parent folders as needed
+	Write(name string, r io.Reader, size int64) error
+	// Mkdir creates a folder and any missing parents
+	Mkdir(name string) error
+	// Rename moves a file or folder to a new name
+	Rename(oldName, newName string) error
+	// Copy copies a single file
+	Copy(src, dst string) error
+	// Delete removes a file, or a folder and all of its contents
+	Delete(name string) error
+	// Open opens a file for reading with seek support
+	Open(name string) (File, error)
+}
+
+// Store is the storage backend selected in the configuration
+var Store Storage
+
+// InitStorage initializes the storage backend selected in config.UploadConfig
+func InitStorage(cfg *config.Config) error {
+	switch cfg.Upload.Storage.Type {
+	case "", "local":
+		local, err := NewLocalStorage(cfg.Upload.DataDir)
+		if err != nil {
+			return fmt.Errorf("failed to initialize local storage: %w", err)
+		}
+		Store = local
+	case "s3":
+		s3, err := NewS3Storage(cfg.Upload.Storage.S3)
+		if err != nil {
+			return fmt.Errorf("failed to initialize s3 storage: %w", err)
+		}
+		Store = s3
+	default:
+		return fmt.Errorf("unknown storage type %q", cfg.Upload.Storage.Type)
+	}
+	return nil
+}
+
+// WriteFile writes a byte slice to the given name
+func WriteFile(s Storage, name string, data []byte) error {
+	return s.Write(name, bytes.NewReader(data), int64(len(data)))
+}
+
+// Exists reports whether a file or folder exists
+func Exists(s Storage, name string) bool {
+	_, err := s.Stat(name)
+	return err == nil
+}
+
+// CopyTree copies a file, or a folder and everything below it
+func CopyTree(s Storage, src, dst string) error {
+	info, err := s.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return s.Copy(src, dst)
+	}
+
+	entries, err := s.List(src)
+	if err != nil {
+		return err
+	}
+	if err := s.Mkdir(dst); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		target := path.Join(dst, entry.Path)
+		if entry.IsDir {
+			err = s.Mkdir(target)
+		} else {
+			err = s.Copy(path.Join(src, entry.Path), target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanName normalizes a storage key
+func cleanName(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	return strings.TrimPrefix(name, "/")
+}
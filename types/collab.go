@@ -0,0 +1,34 @@
+package types
+
+import "encoding/json"
+
+// CollabMessage is a message on the collaborative editing WebSocket. The
+// same shape is used in both directions; which fields are set depends on
+// the type.
+type CollabMessage struct {
+	Type     string                 `json:"type"` // init, op, ack, cursor, join, leave, saved, error
+	ClientID string                 `json:"client_id,omitempty"`
+	Version  int                    `json:"version"`
+	Ops      json.RawMessage        `json:"ops,omitempty"`     // ot.js text operation
+	Content  *string                `json:"content,omitempty"` // document on init
+	Hash     string                 `json:"hash,omitempty"`
+	Anchor   *int                   `json:"anchor,omitempty"`
+	Head     *int                   `json:"head,omitempty"`
+	Client   *CollabClientResponse  `json:"client,omitempty"`
+	Clients  []CollabClientResponse `json:"clients,omitempty"`
+	Message  string                 `json:"message,omitempty"` // message code of errors, and of init after a reload
+}
+
+type CollabClientResponse struct {
+	ClientID    string `json:"client_id"`
+	UserID      uint   `json:"user_id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Anchor      int    `json:"anchor"`
+	Head        int    `json:"head"`
+}
+
+type CollabFileResponse struct {
+	Path    string                 `json:"path"`
+	Clients []CollabClientResponse `json:"clients"`
+}
@@ -6,17 +6,20 @@ type PublicConfigResponse struct {
 	SiteName      string `json:"site_name"`
 	SiteHost      string `json:"site_host"`
 	SecureHost    string `json:"secure_host"`
+	SFTPPort      int    `json:"sftp_port,omitempty"` // set when the SFTP server is enabled
 }
 
 type ConfigResponse struct {
-	AllowRegister       bool              `json:"allow_register"`
-	OAuth               []OAuthConfigFull `json:"oauth"`
-	Replacements        []ReplacementRule `json:"replacements"`
-	AllowedIframeOrigin string            `json:"allowed_iframe_origin"`
-	LogoURL             string            `json:"logo_url"`
-	SiteName            string            `json:"site_name"`
-	SiteHost            string            `json:"site_host"`
-	SecureHost          string            `json:"secure_host"`
+	AllowRegister       bool                `json:"allow_register"`
+	OAuth               []OAuthConfigFull   `json:"oauth"`
+	Replacements        []ReplacementRule   `json:"replacements"`
+	AllowedIframeOrigin string              `json:"allowed_iframe_origin"`
+	LogoURL             string              `json:"logo_url"`
+	SiteName            string              `json:"site_name"`
+	SiteHost            string              `json:"site_host"`
+	SecureHost          string              `json:"secure_host"`
+	Quota               QuotaConfig         `json:"quota"`
+	ContentPolicy       ContentPolicyConfig `json:"content_policy"`
 }
 
 type ReplacementRule struct {
@@ -54,6 +57,41 @@ type UpdateConfigRequest struct {
 	SiteName            string                 `json:"site_name"`
 	SiteHost            string                 `json:"site_host"`
 	SecureHost          string                 `json:"secure_host"`
+	Quota               *QuotaConfig           `json:"quota"`          // optional, unchanged when omitted
+	ContentPolicy       *ContentPolicyConfig   `json:"content_policy"` // optional, unchanged when omitted
+}
+
+type QuotaConfig struct {
+	Normal   QuotaLimit `json:"normal"`
+	Verified QuotaLimit `json:"verified"`
+	Admin    QuotaLimit `json:"admin"`
+}
+
+type QuotaLimit struct {
+	MaxBytes int64 `json:"max_bytes"`
+	MaxFiles int64 `json:"max_files"`
+}
+
+type ContentPolicyConfig struct {
+	Normal   ContentPolicy `json:"normal"`
+	Verified ContentPolicy `json:"verified"`
+	Admin    ContentPolicy `json:"admin"`
+}
+
+type ContentPolicy struct {
+	AllowedExtensions []string         `json:"allowed_extensions"`
+	DeniedExtensions  []string         `json:"denied_extensions"`
+	VerifyMimeType    bool             `json:"verify_mime_type"`
+	MaxSizes          map[string]int64 `json:"max_sizes"`
+	XSSCheck          string           `json:"xss_check"` // warn, block or empty
+	ContentRules      []ContentRule    `json:"content_rules"`
+}
+
+type ContentRule struct {
+	Name       string   `json:"name"`
+	Pattern    string   `json:"pattern"`
+	Extensions []string `json:"extensions"`
+	Action     string   `json:"action"` // warn or block
 }
 
 type OAuthProviderRequest struct {
@@ -0,0 +1,11 @@
+package types
+
+type DeploymentResponse struct {
+	ID        uint   `json:"id"`
+	Number    int    `json:"number"`
+	CreatedBy uint   `json:"created_by"`
+	FileCount int    `json:"file_count"`
+	TotalSize int64  `json:"total_size"`
+	IsActive  bool   `json:"is_active"`
+	CreatedAt string `json:"created_at"`
+}
@@ -5,3 +5,130 @@ type CreateFolderRequest struct {
 	Path string `json:"path" binding:"required"`
 	Name string `json:"name" binding:"required"`
 }
+
+// FileRevisionResponse describes one recorded revision of a file
+type FileRevisionResponse struct {
+	ID        uint   `json:"id"`
+	Path      string `json:"path"`
+	Hash      string `json:"hash"`
+	Size      int64  `json:"size"`
+	UserID    uint   `json:"user_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// RestoreFileRevisionRequest is used for restoring a file revision
+type RestoreFileRevisionRequest struct {
+	RevisionID uint `json:"revision_id" binding:"required"`
+}
+
+// ImportSkippedEntry describes an archive entry that was not imported
+type ImportSkippedEntry struct {
+	Path       string            `json:"path"`
+	Reason     string            `json:"reason"` // unsafe_path, unsupported_type, too_large, conflict, content_policy
+	Violations []PolicyViolation `json:"violations,omitempty"`
+}
+
+// ImportReport summarizes the result of an archive import
+type ImportReport struct {
+	Imported    []string             `json:"imported"`
+	Overwritten []string             `json:"overwritten"`
+	Skipped     []ImportSkippedEntry `json:"skipped"`
+}
+
+// UploadFileResult describes the outcome of one file of a multi-file upload
+type UploadFileResult struct {
+	Path       string            `json:"path"`
+	Size       int64             `json:"size"`
+	Status     string            `json:"status"`               // uploaded, overwritten, skipped
+	Reason     string            `json:"reason,omitempty"`     // unsafe_path, too_large, conflict, content_policy, write_failed (for skipped files)
+	Violations []PolicyViolation `json:"violations,omitempty"` // content policy warnings, or the violations of a skipped file
+}
+
+// PolicyViolation is a rule of the content policy that a file breaks
+type PolicyViolation struct {
+	Path   string `json:"path"`
+	Rule   string `json:"rule"`   // extension, mime_type, max_size, xss, content
+	Action string `json:"action"` // warn or block
+	Name   string `json:"name,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// BatchFileOperation is one step of a batch file request. Paths are relative
+// to the project root.
+type BatchFileOperation struct {
+	Op        string `json:"op" binding:"required"` // create, write, move, copy, delete, mkdir
+	Path      string `json:"path" binding:"required"`
+	Target    string `json:"target"`    // destination path for move and copy
+	Content   string `json:"content"`   // file content for create and write
+	Overwrite bool   `json:"overwrite"` // move and copy: replace an existing target
+}
+
+// BatchFileRequest is used for applying several file operations at once
+type BatchFileRequest struct {
+	Operations []BatchFileOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+// BatchFileResult describes the outcome of one batch operation
+type BatchFileResult struct {
+	Op         string            `json:"op"`
+	Path       string            `json:"path"`
+	Target     string            `json:"target,omitempty"`
+	Status     string            `json:"status"`               // ok, failed, skipped, rolled_back
+	Error      string            `json:"error,omitempty"`      // message code of a failed operation
+	Violations []PolicyViolation `json:"violations,omitempty"` // content policy warnings or violations
+}
+
+// FileSearchRequest is used for searching the text files of a project
+type FileSearchRequest struct {
+	Query         string `form:"query" json:"query" binding:"required"`
+	Regex         bool   `form:"regex" json:"regex"`
+	CaseSensitive bool   `form:"case_sensitive" json:"case_sensitive"`
+	WholeWord     bool   `form:"whole_word" json:"whole_word"`
+	Include       string `form:"include" json:"include"` // comma separated globs, e.g. "*.html,css/**"
+	Exclude       string `form:"exclude" json:"exclude"` // comma separated globs
+	Context       int    `form:"context" json:"context"` // lines of context around each match
+}
+
+// FileSearchMatch is one match of a search
+type FileSearchMatch struct {
+	Line   int      `json:"line"`   // 1-based
+	Column int      `json:"column"` // 1-based, in characters
+	Length int      `json:"length"` // in characters
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// FileSearchResult lists the matches found in one file
+type FileSearchResult struct {
+	Path    string            `json:"path"`
+	Matches []FileSearchMatch `json:"matches"`
+}
+
+// FileSearchResponse is the result of a project search
+type FileSearchResponse struct {
+	Files        []FileSearchResult `json:"files"`
+	TotalMatches int                `json:"total_matches"`
+	Truncated    bool               `json:"truncated"` // true if the match limit was reached
+}
+
+// FileReplaceRequest is used for search-and-replace across a project
+type FileReplaceRequest struct {
+	FileSearchRequest
+	Replacement string `json:"replacement"` // may use $1 style groups in regex mode
+	DryRun      bool   `json:"dry_run"`
+}
+
+// FileReplaceResult describes the changes made to one file
+type FileReplaceResult struct {
+	Path         string `json:"path"`
+	Replacements int    `json:"replacements"`
+	Diff         string `json:"diff"` // unified diff of the change
+}
+
+// FileReplaceResponse is the result of a search-and-replace
+type FileReplaceResponse struct {
+	Files             []FileReplaceResult `json:"files"`
+	TotalReplacements int                 `json:"total_replacements"`
+	DryRun            bool                `json:"dry_run"`
+}
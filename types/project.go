@@ -1,19 +1,32 @@
 package types
 
 type PublicProjectInfoResponse struct {
+	ID          uint   `json:"id"`
 	DisplayName string `json:"display_name"`
+	AllowFork   bool   `json:"allow_fork"`
 }
 
 type CreateProjectRequest struct {
 	Name        string `json:"name" binding:"required"`
 	DisplayName string `json:"display_name"`
 	Description string `json:"description"`
+	Template    string `json:"template"` // name of the template to start from, defaults to "default"
 }
 
 type UpdateProjectRequest struct {
 	DisplayName string `json:"display_name"`
 	Description string `json:"description"`
 	IsSecure    *bool  `json:"is_secure"`
+	AllowFork   *bool  `json:"allow_fork"`
+
+	GitBranch      *string `json:"git_branch"`
+	GitAutoPublish *bool   `json:"git_auto_publish"`
+	GitRemoteURL   *string `json:"git_remote_url"` // empty to unlink the remote
+}
+
+type ForkProjectRequest struct {
+	Name        string `json:"name" binding:"required"`
+	DisplayName string `json:"display_name"` // defaults to the display name of the source
 }
 
 type PublishProjectRequest struct {
@@ -26,21 +39,66 @@ type VerifyProjectPasswordRequest struct {
 }
 
 type ProjectResponse struct {
-	ID          uint   `json:"id"`
-	Name        string `json:"name"`
-	DisplayName string `json:"display_name"`
-	Description string `json:"description"`
-	UserID      uint   `json:"user_id"`
-	Username    string `json:"username"`
-	OwnerType   string `json:"owner_type"`
-	IsPublished bool   `json:"is_published"`
-	IsActive    bool   `json:"is_active"`
-	IsSecure    bool   `json:"is_secure"`
-	HasPassword bool   `json:"has_password"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	DisplayName  string `json:"display_name"`
+	Description  string `json:"description"`
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	OwnerType    string `json:"owner_type"`
+	IsPublished  bool   `json:"is_published"`
+	IsActive     bool   `json:"is_active"`
+	IsSecure     bool   `json:"is_secure"`
+	HasPassword  bool   `json:"has_password"`
+	AllowFork    bool   `json:"allow_fork"`
+	ForkedFromID *uint  `json:"forked_from_id,omitempty"` // project this one was forked from
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+
+	GitBranch      string `json:"git_branch"`
+	GitAutoPublish bool   `json:"git_auto_publish"`
+	GitCommit      string `json:"git_commit"` // last commit synced to the working tree
+	GitRemoteURL   string `json:"git_remote_url"`
+	GitSyncStatus  string `json:"git_sync_status"`
+	GitSyncError   string `json:"git_sync_error"`
+	GitSyncedAt    string `json:"git_synced_at,omitempty"`
 }
 
 type ProjectDetailResponse struct {
 	ProjectResponse
+	GitHookURL string `json:"git_hook_url,omitempty"` // deploy hook that syncs from the git remote
+}
+
+type GitSyncResponse struct {
+	Status   string `json:"status"`
+	Commit   string `json:"commit"`
+	Error    string `json:"error,omitempty"`
+	SyncedAt string `json:"synced_at,omitempty"`
+}
+
+type CreateDeployTokenRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type DeployTokenResponse struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	CreatedBy  uint   `json:"created_by"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	Token      string `json:"token,omitempty"` // only returned when the token is created
+}
+
+type TransferProjectRequest struct {
+	Username string `json:"username" binding:"required"` // recipient
+}
+
+type ProjectTransferResponse struct {
+	ID                 uint   `json:"id"`
+	ProjectID          uint   `json:"project_id"`
+	ProjectName        string `json:"project_name"`
+	ProjectDisplayName string `json:"project_display_name"`
+	FromUsername       string `json:"from_username"`
+	ToUsername         string `json:"to_username"`
+	CreatedAt          string `json:"created_at"`
 }
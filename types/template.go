@@ -0,0 +1,13 @@
+package types
+
+// ProjectTemplateResponse describes a project template
+type ProjectTemplateResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	BuiltIn     bool   `json:"built_in"`
+	PreviewURL  string `json:"preview_url,omitempty"` // empty if the template has no preview image
+	FileCount   int64  `json:"file_count"`
+	Size        int64  `json:"size"`
+	CreatedAt   string `json:"created_at,omitempty"` // empty for built-in templates
+}
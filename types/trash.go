@@ -0,0 +1,18 @@
+package types
+
+type TrashItemResponse struct {
+	ID                uint   `json:"id"`
+	Path              string `json:"path"` // original path inside the project
+	IsFolder          bool   `json:"is_folder"`
+	Size              int64  `json:"size"`
+	FileCount         int64  `json:"file_count"`
+	DeletedBy         uint   `json:"deleted_by"`
+	DeletedByUsername string `json:"deleted_by_username"`
+	DeletedAt         string `json:"deleted_at"`
+	ExpiresAt         string `json:"expires_at,omitempty"` // empty when trash is kept until emptied
+}
+
+// RestoreTrashItemRequest is used for restoring a trash item
+type RestoreTrashItemRequest struct {
+	Overwrite bool `json:"overwrite"` // move whatever is at the original path to the trash first
+}
@@ -24,13 +24,39 @@ type ChangePasswordRequest struct {
 }
 
 type UserResponse struct {
+	ID          uint                `json:"id"`
+	Username    string              `json:"username"`
+	DisplayName string              `json:"display_name"`
+	Email       string              `json:"email"`
+	Type        string              `json:"type"`
+	IsActive    bool                `json:"is_active"`
+	CreatedAt   string              `json:"created_at"`
+	Usage       *QuotaUsageResponse `json:"usage,omitempty"`
+}
+
+type QuotaUsageResponse struct {
+	UsedBytes int64 `json:"used_bytes"`
+	UsedFiles int64 `json:"used_files"`
+	MaxBytes  int64 `json:"max_bytes"` // 0 for unlimited
+	MaxFiles  int64 `json:"max_files"` // 0 for unlimited
+}
+
+type SetUserQuotaRequest struct {
+	MaxBytes *int64 `json:"max_bytes"` // null to use the default for the user type
+	MaxFiles *int64 `json:"max_files"`
+}
+
+type AddSSHKeyRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	PublicKey string `json:"public_key" binding:"required"` // authorized_keys format
+}
+
+type SSHKeyResponse struct {
 	ID          uint   `json:"id"`
-	Username    string `json:"username"`
-	DisplayName string `json:"display_name"`
-	Email       string `json:"email"`
-	Type        string `json:"type"`
-	IsActive    bool   `json:"is_active"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
 	CreatedAt   string `json:"created_at"`
+	LastUsedAt  string `json:"last_used_at,omitempty"`
 }
 
 type LoginResponse struct {
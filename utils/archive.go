@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Supported archive formats
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// ErrUnsupportedArchive is returned for archive formats that cannot be read
+var ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+// ArchiveEntry describes a single entry of an archive
+type ArchiveEntry struct {
+	Name      string // slash-separated name as stored in the archive
+	IsDir     bool
+	IsRegular bool // false for symlinks, devices and other special entries
+	Size      int64
+}
+
+// ArchiveReader is a seekable, random access archive source such as an uploaded file
+type ArchiveReader interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// DetectArchiveFormat returns the archive format of a filename, or "" if unsupported
+func DetectArchiveFormat(filename string) string {
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz
+	}
+	return ""
+}
+
+// WalkArchive calls fn for every entry of an archive, in archive order.
+// content is only valid until fn returns and is nil for non-regular entries.
+func WalkArchive(r ArchiveReader, size int64, format string, fn func(entry ArchiveEntry, content io.Reader) error) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	switch format {
+	case ArchiveZip:
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			entry := ArchiveEntry{
+				Name:      strings.ReplaceAll(f.Name, "\\", "/"),
+				IsDir:     f.FileInfo().IsDir(),
+				IsRegular: f.FileInfo().Mode().IsRegular(),
+				Size:      int64(f.UncompressedSize64),
+			}
+			if !entry.IsRegular {
+				if err := fn(entry, nil); err != nil {
+					return err
+				}
+				continue
+			}
+
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(entry, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			entry := ArchiveEntry{
+				Name:      hdr.Name,
+				IsDir:     hdr.Typeflag == tar.TypeDir,
+				IsRegular: hdr.FileInfo().Mode().IsRegular(),
+				Size:      hdr.Size,
+			}
+			var content io.Reader
+			if entry.IsRegular {
+				content = tr
+			}
+			if err := fn(entry, content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ErrUnsupportedArchive
+}
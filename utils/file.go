@@ -1,12 +1,15 @@
 package utils
 
 import (
-	"fmt"
+	"bytes"
 	"io"
 	"mime"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode/utf8"
 )
 
 // EnsureDir ensures a directory exists, creates if not
@@ -109,6 +112,39 @@ func GetMimeType(filename string) string {
 	return mime.TypeByExtension(filepath.Ext(filename))
 }
 
+// IsTextFile reports whether a file holds text, judged by its MIME type and,
+// for unknown extensions, by sniffing its content
+func IsTextFile(filename string, content []byte) bool {
+	mimeType := GetMimeType(filename)
+	if mimeType == "" {
+		mimeType = http.DetectContentType(content)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	if !IsTextMediaType(mediaType) {
+		return false
+	}
+
+	// Extensions can lie, reject content that is clearly binary
+	return IsUTF8Text(content)
+}
+
+// IsTextMediaType reports whether a media type (without parameters) is text
+func IsTextMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		mediaType == "application/json" ||
+		mediaType == "application/javascript" ||
+		mediaType == "application/xml"
+}
+
+// IsUTF8Text reports whether content is valid UTF-8 without NUL bytes, so it
+// survives being sent as a JSON string
+func IsUTF8Text(content []byte) bool {
+	return !bytes.Contains(content, []byte{0}) && utf8.Valid(content)
+}
+
 // ListFiles lists all files in a directory recursively
 func ListFiles(root string) ([]string, error) {
 	var files []string
@@ -127,45 +163,3 @@ func ListFiles(root string) ([]string, error) {
 	})
 	return files, err
 }
-
-// CreateDefaultIndexHTML creates a default index.html file
-func CreateDefaultIndexHTML(projectName string) string {
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>%s</title>
-    <style>
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif;
-            display: flex;
-            justify-content: center;
-            align-items: center;
-            min-height: 100vh;
-            margin: 0;
-            background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%);
-            color: white;
-        }
-        .container {
-            text-align: center;
-            padding: 2rem;
-        }
-        h1 {
-            font-size: 3rem;
-            margin-bottom: 1rem;
-        }
-        p {
-            font-size: 1.2rem;
-            opacity: 0.9;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>Welcome to %s</h1>
-        <p>Start editing to build your website!</p>
-    </div>
-</body>
-</html>`, projectName, projectName)
-}
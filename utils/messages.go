@@ -53,6 +53,7 @@ const (
 	MsgProjectDeleted         = "success_project_deleted"
 	MsgProjectPublished       = "success_project_published"
 	MsgProjectUnpublished     = "success_project_unpublished"
+	MsgProjectForked          = "success_project_forked"
 
 	// Project error codes
 	MsgInvalidProjectName     = "error_invalid_project_name"
@@ -63,15 +64,31 @@ const (
 	MsgProjectDeleteFailed    = "error_project_delete_failed"
 	MsgProjectPublishFailed   = "error_project_publish_failed"
 	MsgProjectUnpublishFailed = "error_project_unpublish_failed"
+	MsgProjectForkFailed      = "error_project_fork_failed"
+	MsgProjectForkNotAllowed  = "error_project_fork_not_allowed"
+
+	// Deployment success codes
+	MsgDeploymentRolledBack   = "success_deployment_rolled_back"
+
+	// Deployment error codes
+	MsgDeploymentNotFound     = "error_deployment_not_found"
+	MsgDeploymentRollbackFailed = "error_deployment_rollback_failed"
 
 	// File success codes
 	MsgFileUploaded           = "success_file_uploaded"
+	MsgFilesUploaded          = "success_files_uploaded"
 	MsgFileSaved              = "success_file_saved"
 	MsgFileDeleted            = "success_file_deleted"
 	MsgFileRenamed            = "success_file_renamed"
 	MsgFileMoved              = "success_file_moved"
+	MsgFileCopied             = "success_file_copied"
 	MsgDirectoryCreated       = "success_directory_created"
 	MsgDirectoryDeleted       = "success_directory_deleted"
+	MsgFileBatchApplied       = "success_file_batch_applied"
+	MsgFilesReplaced          = "success_files_replaced"
+
+	// File history success codes
+	MsgFileRestored           = "success_file_restored"
 
 	// File error codes
 	MsgFileNotFound           = "error_file_not_found"
@@ -81,10 +98,75 @@ const (
 	MsgFileWriteFailed        = "error_file_write_failed"
 	MsgFileRenameFailed       = "error_file_rename_failed"
 	MsgFileMoveFailed         = "error_file_move_failed"
+	MsgFileCopyFailed         = "error_file_copy_failed"
 	MsgInvalidFilePath        = "error_invalid_file_path"
+	MsgFileConflict           = "error_file_conflict"
+	MsgFileNotText            = "error_file_not_text"
+	MsgContentPolicyViolation = "error_content_policy_violation"
+	MsgCollabUnsupportedFile  = "error_collab_unsupported_file"
+	MsgCollabInvalidOperation = "error_collab_invalid_operation"
+	MsgCollabFileChanged      = "error_collab_file_changed"
 	MsgInvalidFileName        = "error_invalid_file_name"
 	MsgDirectoryCreationFailed = "error_directory_creation_failed"
 	MsgDirectoryDeleteFailed  = "error_directory_delete_failed"
+	MsgFileExists             = "error_file_exists"
+	MsgFileBatchInvalid       = "error_file_batch_invalid"
+	MsgFileBatchFailed        = "error_file_batch_failed"
+	MsgInvalidSearchPattern   = "error_invalid_search_pattern"
+
+	// File history error codes
+	MsgRevisionNotFound       = "error_revision_not_found"
+
+	// Import success codes
+	MsgProjectImported        = "success_project_imported"
+
+	// Import error codes
+	MsgInvalidArchive         = "error_invalid_archive"
+	MsgProjectImportFailed    = "error_project_import_failed"
+
+	// Trash success codes
+	MsgTrashItemRestored      = "success_trash_item_restored"
+	MsgTrashItemPurged        = "success_trash_item_purged"
+	MsgTrashEmptied           = "success_trash_emptied"
+
+	// Trash error codes
+	MsgTrashItemNotFound      = "error_trash_item_not_found"
+	MsgTrashRestoreFailed     = "error_trash_restore_failed"
+	MsgTrashPurgeFailed       = "error_trash_purge_failed"
+
+	// Template success codes
+	MsgTemplateCreated        = "success_template_created"
+	MsgTemplateDeleted        = "success_template_deleted"
+
+	// Template error codes
+	MsgTemplateNotFound       = "error_template_not_found"
+	MsgTemplateExists         = "error_template_exists"
+	MsgInvalidTemplateName    = "error_invalid_template_name"
+	MsgInvalidTemplate        = "error_invalid_template"
+
+	// Transfer success codes
+	MsgTransferRequested      = "success_transfer_requested"
+	MsgTransferAccepted       = "success_transfer_accepted"
+	MsgTransferDeclined       = "success_transfer_declined"
+	MsgTransferCanceled       = "success_transfer_canceled"
+
+	// Transfer error codes
+	MsgTransferNotFound       = "error_transfer_not_found"
+	MsgInvalidTransferTarget  = "error_invalid_transfer_target"
+	MsgTransferFailed         = "error_transfer_failed"
+
+	// Git success codes
+	MsgDeployTokenCreated     = "success_deploy_token_created"
+	MsgDeployTokenDeleted     = "success_deploy_token_deleted"
+	MsgGitSynced              = "success_git_synced"
+	MsgGitSyncStarted         = "success_git_sync_started"
+
+	// Git error codes
+	MsgInvalidGitBranch       = "error_invalid_git_branch"
+	MsgDeployTokenNotFound    = "error_deploy_token_not_found"
+	MsgInvalidGitRemote       = "error_invalid_git_remote"
+	MsgGitRemoteNotSet        = "error_git_remote_not_set"
+	MsgGitSyncFailed          = "error_git_sync_failed"
 
 	// User success codes
 	MsgUserUpdated            = "success_user_updated"
@@ -99,11 +181,24 @@ const (
 	MsgOldPasswordIncorrect   = "error_old_password_incorrect"
 	MsgCannotDeleteSelf       = "error_cannot_delete_self"
 
+	// SSH key success codes
+	MsgSSHKeyAdded            = "success_ssh_key_added"
+	MsgSSHKeyDeleted          = "success_ssh_key_deleted"
+
+	// SSH key error codes
+	MsgInvalidSSHKey          = "error_invalid_ssh_key"
+	MsgSSHKeyExists           = "error_ssh_key_exists"
+	MsgSSHKeyNotFound         = "error_ssh_key_not_found"
+
+	// Quota error codes
+	MsgQuotaExceeded          = "error_quota_exceeded"
+
 	// Config success codes
 	MsgConfigUpdated          = "success_config_updated"
 
 	// Config error codes
 	MsgConfigUpdateFailed     = "error_config_update_failed"
+	MsgInvalidContentPolicy   = "error_invalid_content_policy"
 	MsgInvalidConfig          = "error_invalid_config"
 
 	// Analytics error codes
@@ -47,6 +47,15 @@ func Error(c *gin.Context, code int, messageCode string) {
 	})
 }
 
+// ErrorWithData sends an error response with message code and data
+func ErrorWithData(c *gin.Context, code int, messageCode string, data interface{}) {
+	c.JSON(http.StatusOK, Response{
+		Code:    code,
+		Message: messageCode,
+		Data:    data,
+	})
+}
+
 // ErrorWithStatus sends an error response with HTTP status code and message code
 func ErrorWithStatus(c *gin.Context, httpStatus int, code int, messageCode string) {
 	c.JSON(httpStatus, Response{
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// SearchMatch is one occurrence of a search pattern inside a file
+type SearchMatch struct {
+	Line   int    // 1-based line number
+	Column int    // 1-based column, in characters
+	Length int    // length of the match, in characters
+	Text   string // the whole matching line
+	Before []string
+	After  []string
+}
+
+// CompileSearchPattern builds the regular expression for a search query.
+// Literal queries are escaped; wholeWord only matches at word boundaries.
+func CompileSearchPattern(query string, isRegex, caseSensitive, wholeWord bool) (*regexp.Regexp, error) {
+	pattern := query
+	if !isRegex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if wholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// MatchGlobs reports whether a slash-separated relative path matches any of
+// the comma separated glob patterns. Patterns without a slash match the file
+// name, patterns ending in "/**" match everything below a folder, and all
+// other patterns match the whole path.
+func MatchGlobs(patterns, name string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+
+		if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if name == dir || strings.HasPrefix(name, dir+"/") {
+				return true
+			}
+			continue
+		}
+
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchContent returns the matches of re in content, line by line, with up
+// to contextLines lines before and after each match. At most limit matches
+// are returned when limit is positive.
+func SearchContent(content string, re *regexp.Regexp, contextLines, limit int) []SearchMatch {
+	lines := strings.Split(content, "\n")
+	var matches []SearchMatch
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			if loc[0] == loc[1] {
+				// Skip empty matches of patterns like "a*"
+				continue
+			}
+			match := SearchMatch{
+				Line:   i + 1,
+				Column: utf8.RuneCountInString(line[:loc[0]]) + 1,
+				Length: utf8.RuneCountInString(line[loc[0]:loc[1]]),
+				Text:   line,
+			}
+			if contextLines > 0 {
+				match.Before = contextSlice(lines, i-contextLines, i)
+				match.After = contextSlice(lines, i+1, i+1+contextLines)
+			}
+			matches = append(matches, match)
+			if limit > 0 && len(matches) >= limit {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// contextSlice returns lines[from:to] clamped to the slice bounds
+func contextSlice(lines []string, from, to int) []string {
+	from = max(from, 0)
+	to = min(to, len(lines))
+	if from >= to {
+		return nil
+	}
+	result := make([]string, 0, to-from)
+	for _, line := range lines[from:to] {
+		result = append(result, strings.TrimSuffix(line, "\r"))
+	}
+	return result
+}
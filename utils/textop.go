@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"unicode/utf16"
+)
+
+// ErrInvalidTextOperation is returned for operations that are malformed or
+// do not fit the document they are applied to
+var ErrInvalidTextOperation = errors.New("invalid text operation")
+
+// maxTextOperationLength bounds the characters a decoded operation may
+// retain and delete in total, so adding them up cannot overflow
+const maxTextOperationLength = 1 << 30
+
+// TextOperation is an edit of a whole text document for operational
+// transformation, in the JSON format of ot.js: a list where a positive
+// number retains that many characters, a negative number deletes that many
+// and a string is inserted. Lengths count UTF-16 code units, like JavaScript
+// strings, so they match offsets in the browser editor.
+type TextOperation []TextOpComponent
+
+// TextOpComponent is one step of a TextOperation; exactly one field is set
+type TextOpComponent struct {
+	Retain int
+	Delete int
+	Insert []uint16
+}
+
+// retain appends a retain, merging it with a preceding one
+func (op TextOperation) retain(n int) TextOperation {
+	if n <= 0 {
+		return op
+	}
+	if last := len(op) - 1; last >= 0 && op[last].Retain > 0 {
+		op[last].Retain += n
+		return op
+	}
+	return append(op, TextOpComponent{Retain: n})
+}
+
+// insert appends an insert, keeping inserts before deletes at the same
+// position so equal edits have a single representation
+func (op TextOperation) insert(s []uint16) TextOperation {
+	if len(s) == 0 {
+		return op
+	}
+	last := len(op) - 1
+	if last >= 0 && op[last].Insert != nil {
+		op[last].Insert = append(slices.Clip(op[last].Insert), s...)
+		return op
+	}
+	if last >= 0 && op[last].Delete > 0 {
+		if last > 0 && op[last-1].Insert != nil {
+			op[last-1].Insert = append(slices.Clip(op[last-1].Insert), s...)
+			return op
+		}
+		op = append(op, op[last])
+		op[last] = TextOpComponent{Insert: s}
+		return op
+	}
+	return append(op, TextOpComponent{Insert: s})
+}
+
+// delete appends a delete, merging it with a preceding one
+func (op TextOperation) delete(n int) TextOperation {
+	if n <= 0 {
+		return op
+	}
+	if last := len(op) - 1; last >= 0 && op[last].Delete > 0 {
+		op[last].Delete += n
+		return op
+	}
+	return append(op, TextOpComponent{Delete: n})
+}
+
+// BaseLength returns the length of the documents the operation applies to
+func (op TextOperation) BaseLength() int {
+	n := 0
+	for _, c := range op {
+		n += c.Retain + c.Delete
+	}
+	return n
+}
+
+// Apply returns the document after the operation
+func (op TextOperation) Apply(doc []uint16) ([]uint16, error) {
+	if op.BaseLength() != len(doc) {
+		return nil, ErrInvalidTextOperation
+	}
+
+	result := make([]uint16, 0, len(doc))
+	pos := 0
+	for _, c := range op {
+		if c.Retain > len(doc)-pos || c.Delete > len(doc)-pos {
+			return nil, ErrInvalidTextOperation
+		}
+		switch {
+		case c.Retain > 0:
+			result = append(result, doc[pos:pos+c.Retain]...)
+			pos += c.Retain
+		case c.Delete > 0:
+			pos += c.Delete
+		default:
+			result = append(result, c.Insert...)
+		}
+	}
+	return result, nil
+}
+
+// TransformTextOperations transforms two operations made concurrently on
+// the same document, returning a' and b' such that applying a then b' gives
+// the same document as applying b then a'. Inserts of a at the same
+// position as inserts of b come first.
+func TransformTextOperations(a, b TextOperation) (TextOperation, TextOperation, error) {
+	if a.BaseLength() != b.BaseLength() {
+		return nil, nil, ErrInvalidTextOperation
+	}
+
+	var aPrime, bPrime TextOperation
+	i, j := 0, 0
+	var ca, cb TextOpComponent
+	if i < len(a) {
+		ca = a[i]
+	}
+	if j < len(b) {
+		cb = b[j]
+	}
+	nextA := func() {
+		i++
+		ca = TextOpComponent{}
+		if i < len(a) {
+			ca = a[i]
+		}
+	}
+	nextB := func() {
+		j++
+		cb = TextOpComponent{}
+		if j < len(b) {
+			cb = b[j]
+		}
+	}
+
+	for i < len(a) || j < len(b) {
+		// Inserts do not depend on the other operation
+		if i < len(a) && ca.Insert != nil {
+			aPrime = aPrime.insert(ca.Insert)
+			bPrime = bPrime.retain(len(ca.Insert))
+			nextA()
+			continue
+		}
+		if j < len(b) && cb.Insert != nil {
+			aPrime = aPrime.retain(len(cb.Insert))
+			bPrime = bPrime.insert(cb.Insert)
+			nextB()
+			continue
+		}
+		if i >= len(a) || j >= len(b) {
+			return nil, nil, ErrInvalidTextOperation
+		}
+
+		switch {
+		case ca.Retain > 0 && cb.Retain > 0:
+			n := min(ca.Retain, cb.Retain)
+			aPrime = aPrime.retain(n)
+			bPrime = bPrime.retain(n)
+			ca.Retain -= n
+			cb.Retain -= n
+		case ca.Delete > 0 && cb.Delete > 0:
+			// Both deleted the same text
+			n := min(ca.Delete, cb.Delete)
+			ca.Delete -= n
+			cb.Delete -= n
+		case ca.Delete > 0 && cb.Retain > 0:
+			n := min(ca.Delete, cb.Retain)
+			aPrime = aPrime.delete(n)
+			ca.Delete -= n
+			cb.Retain -= n
+		case ca.Retain > 0 && cb.Delete > 0:
+			n := min(ca.Retain, cb.Delete)
+			bPrime = bPrime.delete(n)
+			ca.Retain -= n
+			cb.Delete -= n
+		default:
+			return nil, nil, ErrInvalidTextOperation
+		}
+
+		if ca.Retain == 0 && ca.Delete == 0 {
+			nextA()
+		}
+		if cb.Retain == 0 && cb.Delete == 0 {
+			nextB()
+		}
+	}
+
+	return aPrime, bPrime, nil
+}
+
+// MarshalJSON encodes the operation in the ot.js format
+func (op TextOperation) MarshalJSON() ([]byte, error) {
+	components := make([]any, 0, len(op))
+	for _, c := range op {
+		switch {
+		case c.Retain > 0:
+			components = append(components, c.Retain)
+		case c.Delete > 0:
+			components = append(components, -c.Delete)
+		default:
+			components = append(components, string(utf16.Decode(c.Insert)))
+		}
+	}
+	return json.Marshal(components)
+}
+
+// UnmarshalJSON decodes an operation in the ot.js format
+func (op *TextOperation) UnmarshalJSON(data []byte) error {
+	var components []any
+	if err := json.Unmarshal(data, &components); err != nil {
+		return ErrInvalidTextOperation
+	}
+
+	result := TextOperation{}
+	length := 0
+	for _, component := range components {
+		switch v := component.(type) {
+		case float64:
+			if v > maxTextOperationLength || v < -maxTextOperationLength {
+				return ErrInvalidTextOperation
+			}
+			n := int(v)
+			if float64(n) != v || n == 0 {
+				return ErrInvalidTextOperation
+			}
+			if length += max(n, -n); length > maxTextOperationLength {
+				return ErrInvalidTextOperation
+			}
+			if n > 0 {
+				result = result.retain(n)
+			} else {
+				result = result.delete(-n)
+			}
+		case string:
+			if v == "" {
+				return ErrInvalidTextOperation
+			}
+			result = result.insert(utf16.Encode([]rune(v)))
+		default:
+			return ErrInvalidTextOperation
+		}
+	}
+	*op = result
+	return nil
+}
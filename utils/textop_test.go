@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"unicode/utf16"
+)
+
+func parseTextOperation(t *testing.T, s string) TextOperation {
+	t.Helper()
+	var op TextOperation
+	if err := json.Unmarshal([]byte(s), &op); err != nil {
+		t.Fatalf("unmarshal %s: %v", s, err)
+	}
+	return op
+}
+
+func applyText(t *testing.T, op TextOperation, doc string) string {
+	t.Helper()
+	result, err := op.Apply(utf16.Encode([]rune(doc)))
+	if err != nil {
+		t.Fatalf("apply %v to %q: %v", op, doc, err)
+	}
+	return string(utf16.Decode(result))
+}
+
+func TestTransformTextOperationsConverges(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		a, b string
+		want string
+	}{
+		{"inserts at different positions", "hello", `[1,"A",4]`, `[4,"B",1]`, "hAellBo"},
+		{"inserts at the same position", "hello", `[2,"A",3]`, `[2,"B",3]`, "heABllo"},
+		{"insert at the start and end", "abc", `["X",3]`, `[3,"Y"]`, "XabcY"},
+		{"overlapping deletes", "abcdef", `[1,-3,2]`, `[2,-3,1]`, "af"},
+		{"same delete", "abcdef", `[2,-2,2]`, `[2,-2,2]`, "abef"},
+		{"insert inside a delete", "abcdef", `[1,-4,1]`, `[3,"X",3]`, "aXf"},
+		{"delete everything and insert", "abc", `[-3]`, `[1,"X",2]`, "X"},
+		{"replace and insert", "hello world", `[6,-5,"there"]`, `["Oh, ",11]`, "Oh, hello there"},
+		{"empty document", "", `["a"]`, `["b"]`, "ab"},
+		{"surrogate pairs", "a😀b", `[1,-2,1]`, `[3,"🎉",1]`, "a🎉b"},
+		{"insert after a surrogate pair", "😀", `[2,"x"]`, `["y",2]`, "y😀x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := parseTextOperation(t, tt.a)
+			b := parseTextOperation(t, tt.b)
+			aPrime, bPrime, err := TransformTextOperations(a, b)
+			if err != nil {
+				t.Fatalf("transform: %v", err)
+			}
+
+			ab := applyText(t, bPrime, applyText(t, a, tt.doc))
+			ba := applyText(t, aPrime, applyText(t, b, tt.doc))
+			if ab != ba {
+				t.Fatalf("documents diverge: a then b' gives %q, b then a' gives %q", ab, ba)
+			}
+			if ab != tt.want {
+				t.Errorf("got %q, want %q", ab, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformTextOperationsRejectsMismatchedLengths(t *testing.T) {
+	a := parseTextOperation(t, `[3]`)
+	b := parseTextOperation(t, `[4]`)
+	if _, _, err := TransformTextOperations(a, b); err != ErrInvalidTextOperation {
+		t.Errorf("got %v, want ErrInvalidTextOperation", err)
+	}
+}
+
+func TestApplyTextOperationBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		op   TextOperation
+		ok   bool
+	}{
+		{"retain counts UTF-16 code units", "😀", TextOperation{{Retain: 2}}, true},
+		{"retain past the end", "ab", TextOperation{{Retain: 3}}, false},
+		{"retain short of the end", "abc", TextOperation{{Retain: 2}}, false},
+		{"delete past the end", "ab", TextOperation{{Retain: 1}, {Delete: 2}}, false},
+		{"lengths adding up after a negative one", "hello", TextOperation{{Retain: 7}, {Delete: -2}}, false},
+		{"insert into an empty document", "", TextOperation{{Insert: []uint16{'x'}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.op.Apply(utf16.Encode([]rune(tt.doc)))
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && err != ErrInvalidTextOperation {
+				t.Errorf("got %v, want ErrInvalidTextOperation", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTextOperation(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		ok   bool
+	}{
+		{"retain, insert and delete", `[2,"x",-3]`, true},
+		{"zero", `[0]`, false},
+		{"fraction", `[1.5]`, false},
+		{"empty insert", `[""]`, false},
+		{"other types", `[true]`, false},
+		{"not a list", `{"retain":1}`, false},
+		{"length beyond the limit", `[1e300]`, false},
+		{"lengths overflowing together", `[4611686018427387904,4611686018427387904,5]`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var op TextOperation
+			err := json.Unmarshal([]byte(tt.json), &op)
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("decoded %s as %v, want an error", tt.json, op)
+			}
+		})
+	}
+}
+
+func TestMarshalTextOperationRoundTrip(t *testing.T) {
+	for _, s := range []string{`[2,"x",-3]`, `["😀",1]`, `["ab",-1]`} {
+		op := parseTextOperation(t, s)
+		data, err := json.Marshal(op)
+		if err != nil {
+			t.Fatalf("marshal %s: %v", s, err)
+		}
+		if string(data) != s {
+			t.Errorf("got %s, want %s", data, s)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"net"
+	"net/url"
 	"regexp"
 	"strings"
 )
@@ -15,13 +17,11 @@ var (
 	// ProjectNameRegex validates project name (alphanumeric, underscore, hyphen, 3-100 chars)
 	ProjectNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,100}$`)
 
-	// AllowedFileExtensions defines allowed file extensions for upload
-	AllowedFileExtensions = []string{
-		".html", ".htm", ".css", ".js", ".json",
-		".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".ico",
-		".txt", ".md", ".xml", ".pdf",
-		".woff", ".woff2", ".ttf", ".eot", ".otf",
-	}
+	// TemplateNameRegex validates project template name (lowercase alphanumeric, hyphen, 2-50 chars)
+	TemplateNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+	// GitBranchRegex validates the characters of a git branch name (1-255 chars)
+	GitBranchRegex = regexp.MustCompile(`^[a-zA-Z0-9._/-]{1,255}$`)
 
 	// DangerousPatterns defines potentially dangerous patterns in HTML/JS
 	DangerousPatterns = []*regexp.Regexp{
@@ -48,17 +48,81 @@ func ValidateProjectName(name string) bool {
 	return ProjectNameRegex.MatchString(name)
 }
 
+// ValidateTemplateName validates project template name format
+func ValidateTemplateName(name string) bool {
+	return TemplateNameRegex.MatchString(name)
+}
+
+// ValidateGitBranch validates a git branch name, following the rules of
+// git check-ref-format for the characters GitBranchRegex allows
+func ValidateGitBranch(name string) bool {
+	if !GitBranchRegex.MatchString(name) {
+		return false
+	}
+	if strings.HasPrefix(name, "-") || strings.Contains(name, "..") || strings.Contains(name, "//") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+	return !strings.HasSuffix(name, ".")
+}
+
+// ValidateGitRemoteURL validates a git remote URL. Only http(s) and git
+// remotes are accepted, plus file remotes when allowFile is set. Unless
+// allowPrivate is set, hosts must not be or resolve to internal addresses.
+func ValidateGitRemoteURL(rawURL string, allowFile, allowPrivate bool) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "git":
+		if u.Hostname() == "" {
+			return false
+		}
+		return allowPrivate || !IsInternalHost(u.Hostname())
+	case "file":
+		return allowFile && u.Path != ""
+	}
+	return false
+}
+
+// IsInternalHost reports whether a host is, or resolves to, a loopback,
+// private, link-local or otherwise non-public address. Hosts that cannot be
+// resolved are treated as internal.
+func IsInternalHost(host string) bool {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		if ips, err = net.LookupIP(host); err != nil || len(ips) == 0 {
+			return true
+		}
+	}
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+			ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+			ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidatePassword validates password strength
 func ValidatePassword(password string) bool {
 	// At least 6 characters
 	return len(password) >= 6
 }
 
-// IsAllowedFileExtension checks if file extension is allowed
-func IsAllowedFileExtension(filename string) bool {
+// HasFileExtension checks if a file name ends with one of the extensions,
+// ignoring case
+func HasFileExtension(filename string, extensions []string) bool {
 	lowerFilename := strings.ToLower(filename)
-	for _, ext := range AllowedFileExtensions {
-		if strings.HasSuffix(lowerFilename, ext) {
+	for _, ext := range extensions {
+		if ext != "" && strings.HasSuffix(lowerFilename, strings.ToLower(ext)) {
 			return true
 		}
 	}